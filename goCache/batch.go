@@ -0,0 +1,267 @@
+package goCache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCache/pb"
+	"sync"
+	"time"
+)
+
+// BatchError 批量获取时部分 key 加载失败, 其余 key 的结果仍然有效
+type BatchError struct {
+	Keys []string // 加载失败的 key
+	Err  error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("failed to load keys %v, err: %v", e.Keys, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchGetter 可选的批量加载接口, Getter 同时实现时 GetMany 使用它一次加载所有本地未命中的 key
+// 返回结果中不存在的 key 视为未找到
+type BatchGetter interface {
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// GetMany 批量获取缓存
+// 本地命中的 key 直接返回, 其余 key 按 PeerPicker 分组, 每个 peer 只发送一次批量请求,
+// 属于本节点的 key 通过 BatchGetter 或逐个通过 Getter 加载
+// 返回已获取到的结果, 部分 key 加载失败时同时返回包含 BatchError 的错误, 不存在的 key 不在结果中且不视为错误
+func (c *Group) GetMany(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	var (
+		values, missing, _ = c.lookupMany(keys)
+		local              []string
+		remote             = make(map[string][]string)
+		peers              = make(map[string]PeerGetter)
+	)
+	for _, key := range missing {
+		if c.peer != nil {
+			if peer, ok := c.peer.PickPeer(key); ok {
+				remote[peer.Name()] = append(remote[peer.Name()], key)
+				peers[peer.Name()] = peer
+				continue
+			}
+		}
+		local = append(local, key)
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for name, keys := range remote {
+		wg.Add(1)
+		go func(peer PeerGetter, keys []string) {
+			defer wg.Done()
+			data, err := c.loadManyFromPeer(ctx, keys, peer)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			for key, v := range data {
+				values[key] = v
+			}
+		}(peers[name], keys)
+	}
+
+	data, _, err := c.loadManyLocally(ctx, local, c.load)
+	wg.Wait()
+	for key, v := range data {
+		values[key] = v
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return values, errors.Join(errs...)
+}
+
+// getManyLocally 处理其他节点转发的批量请求, 未命中的 key 在本节点加载, 不再转发, 同时返回不存在的 key
+func (c *Group) getManyLocally(ctx context.Context, keys []string) (map[string]ByteView, []string, error) {
+	values, missing, notFound := c.lookupMany(keys)
+	data, absent, err := c.loadManyLocally(ctx, missing, c.loadLocallyOnce)
+	for key, v := range data {
+		values[key] = v
+	}
+	return values, append(notFound, absent...), err
+}
+
+// lookupMany 查找本地缓存, 返回命中的结果, 需要加载的 key 与负缓存中的 key
+func (c *Group) lookupMany(keys []string) (map[string]ByteView, []string, []string) {
+	var (
+		values   = make(map[string]ByteView, len(keys))
+		seen     = make(map[string]bool, len(keys))
+		missing  []string
+		notFound []string
+	)
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		c.stats.gets.Add(1)
+		if v, ok := c.lookupCache(key); ok {
+			c.track(key)
+			c.revalidate(key, v)
+			values[key] = v
+			continue
+		}
+		if c.lookupNegative(key) {
+			notFound = append(notFound, key)
+			continue
+		}
+		missing = append(missing, key)
+	}
+	return values, missing, notFound
+}
+
+func (c *Group) loadManyFromPeer(ctx context.Context, keys []string, peer PeerGetter) (map[string]ByteView, error) {
+	c.stats.peerLoads.Add(1)
+	start := time.Now()
+	data, err := peer.GetMany(ctx, c.name, keys)
+	peerLatency.Since(start, c.name, peer.Name())
+	peerRequests.Inc(c.name, peer.Name(), "getmany", result(err))
+	var batchErr *BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		c.stats.peerErrors.Add(1)
+		return nil, fmt.Errorf("failed to get from peer %s, err: %w", peer.Name(), err)
+	}
+	failed := make(map[string]bool)
+	if batchErr != nil {
+		for _, key := range batchErr.Keys {
+			failed[key] = true
+		}
+	}
+	values := make(map[string]ByteView, len(data))
+	for _, key := range keys {
+		if v, ok := data[key]; ok {
+			values[key] = ByteView{b: v}
+		} else if !failed[key] {
+			// 所有者标记为不存在的 key, 在本节点负缓存
+			c.setNegative(key)
+		}
+	}
+	if err != nil {
+		// 所有者只有部分 key 加载失败时, 其余 key 的结果仍然返回
+		c.stats.peerErrors.Add(1)
+		return values, fmt.Errorf("failed to get from peer %s, err: %w", peer.Name(), err)
+	}
+	return values, nil
+}
+
+// loadManyLocally 通过 BatchGetter 一次加载, 未实现时逐个通过 load 加载
+// 返回加载的结果与不存在的 key, 加载失败的 key 通过 BatchError 返回
+func (c *Group) loadManyLocally(ctx context.Context, keys []string, load func(ctx context.Context, key string) (ByteView, error)) (map[string]ByteView, []string, error) {
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	values := make(map[string]ByteView, len(keys))
+	if c.batchGetter == nil {
+		var (
+			notFound []string
+			failed   []string
+			errs     []error
+		)
+		for _, key := range keys {
+			v, err := load(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				notFound = append(notFound, key)
+				continue
+			}
+			if err != nil {
+				failed = append(failed, key)
+				errs = append(errs, err)
+				continue
+			}
+			values[key] = v
+		}
+		if len(failed) > 0 {
+			return values, notFound, &BatchError{Keys: failed, Err: errors.Join(errs...)}
+		}
+		return values, notFound, nil
+	}
+
+	c.stats.localLoads.Add(1)
+	start := time.Now()
+	data, err := c.batchGetter.GetMany(ctx, keys)
+	getterLatency.Since(start, c.name)
+	if err != nil {
+		c.stats.loaderErrors.Add(1)
+		return nil, nil, &BatchError{Keys: keys, Err: err}
+	}
+	var notFound []string
+	for _, key := range keys {
+		if v, ok := data[key]; ok {
+			values[key] = c.setLoaded(key, v)
+		} else {
+			c.setNegative(key)
+			notFound = append(notFound, key)
+		}
+	}
+	return values, notFound, nil
+}
+
+// getManyResponse 构造批量响应, 不存在的 key 通过 not_found 返回, 加载失败的 key 通过 failed 返回, 错误信息放在 msg 中
+func getManyResponse(keys []string, values map[string]ByteView, notFound []string, err error) *pb.GetManyResponse {
+	resp := &pb.GetManyResponse{
+		Values:   make(map[string][]byte, len(values)),
+		Msg:      "success",
+		NotFound: notFound,
+	}
+	for key, v := range values {
+		resp.Values[key] = v.Slice()
+	}
+	if err == nil {
+		return resp
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		resp.Msg = batchErr.Err.Error()
+		resp.Failed = batchErr.Keys
+		return resp
+	}
+	resp.Msg = err.Error()
+	resp.Failed = unmarked(keys, resp)
+	return resp
+}
+
+// getManyResult 将批量响应中加载失败的 key 还原为 BatchError, 所有者没有标记为不存在的 key 同样视为加载失败,
+// 因此结果中不存在且不在 BatchError 中的 key 都由所有者确认不存在
+func getManyResult(keys []string, resp *pb.GetManyResponse) (map[string][]byte, error) {
+	failed := unmarked(keys, resp)
+	if len(failed) == 0 {
+		return resp.GetValues(), nil
+	}
+	msg := resp.GetMsg()
+	if len(resp.GetFailed()) == 0 {
+		msg = "keys missing from response"
+	}
+	return resp.GetValues(), &BatchError{Keys: failed, Err: errors.New(msg)}
+}
+
+// unmarked 返回既没有结果也没有标记为不存在的 key
+func unmarked(keys []string, resp *pb.GetManyResponse) []string {
+	notFound := make(map[string]bool, len(resp.GetNotFound()))
+	for _, key := range resp.GetNotFound() {
+		notFound[key] = true
+	}
+	var (
+		failed []string
+		seen   = make(map[string]bool, len(keys))
+	)
+	for _, key := range keys {
+		if _, ok := resp.GetValues()[key]; ok || notFound[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		failed = append(failed, key)
+	}
+	return failed
+}
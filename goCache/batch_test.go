@@ -0,0 +1,250 @@
+package goCache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCache/pb"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePeer 以 remote- 开头的 key 属于远端 peer
+type fakePeer struct {
+	getter *fakeGetter
+	owner  PeerGetter // 不为空时代替 getter 作为远端 peer
+}
+
+func (f *fakePeer) SetService(node *pb.ServiceNode)    {}
+func (f *fakePeer) DelService(name string)             {}
+func (f *fakePeer) StartService()                      {}
+func (f *fakePeer) Shutdown(ctx context.Context) error { return nil }
+func (f *fakePeer) PickPeer(key string) (PeerGetter, bool) {
+	if strings.HasPrefix(key, "remote-") {
+		if f.owner != nil {
+			return f.owner, true
+		}
+		return f.getter, true
+	}
+	return nil, false
+}
+
+func (f *fakePeer) PickPeers(key string, n int) []PeerGetter {
+	if peer, ok := f.PickPeer(key); ok {
+		return []PeerGetter{peer}
+	}
+	return []PeerGetter{nil}
+}
+
+// fakeGetter 返回 key 作为 value, err 不为空时所有请求失败
+type fakeGetter struct {
+	name    string
+	err     error
+	gets    atomic.Int32
+	batches atomic.Int32
+	mu      sync.Mutex
+	sets    map[string][]byte
+	softs   map[string]time.Duration
+	expires map[string]time.Duration
+}
+
+func (f *fakeGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	f.gets.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(key), nil
+}
+
+func (f *fakeGetter) GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	f.batches.Add(1)
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		values[key] = []byte(key)
+	}
+	return values, nil
+}
+
+func (f *fakeGetter) Remove(ctx context.Context, group string, key string) error { return f.err }
+
+func (f *fakeGetter) Set(ctx context.Context, group string, key string, value []byte, expire time.Duration) error {
+	return f.set(key, value, 0, expire)
+}
+
+func (f *fakeGetter) Handoff(ctx context.Context, group string, key string, value []byte, soft, expire time.Duration) error {
+	return f.set(key, value, soft, expire)
+}
+
+// set 记录写入的数据与剩余的过期时间
+func (f *fakeGetter) set(key string, value []byte, soft, expire time.Duration) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sets == nil {
+		f.sets = make(map[string][]byte)
+		f.softs = make(map[string]time.Duration)
+		f.expires = make(map[string]time.Duration)
+	}
+	f.sets[key], f.softs[key], f.expires[key] = value, soft, expire
+	return nil
+}
+
+func (f *fakeGetter) Name() string {
+	if f.name == "" {
+		return "fake"
+	}
+	return f.name
+}
+
+func (f *fakeGetter) Addr() string { return f.Name() }
+
+type batchDB struct {
+	batches atomic.Int32
+}
+
+func (b *batchDB) Get(key string) ([]byte, error) {
+	return nil, fmt.Errorf("Get should not be called")
+}
+
+func (b *batchDB) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	b.batches.Add(1)
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if v, ok := db[key]; ok {
+			values[key] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+func TestGroup_GetMany(t *testing.T) {
+	var (
+		loader = &batchDB{}
+		peer   = &fakePeer{getter: &fakeGetter{}}
+	)
+	group := NewGroup("batch", loader)
+	group.RegisterPeer(peer)
+	group.Set("cached", []byte("v"), defaultExpire)
+
+	keys := []string{"cached", "Tom", "Jack", "unknown", "remote-1", "remote-2", "Tom"}
+	values, err := group.GetMany(context.Background(), keys)
+	if err != nil {
+		t.Fatalf("GetMany failed, err: %v", err)
+	}
+	want := map[string]string{
+		"cached":   "v",
+		"Tom":      "123",
+		"Jack":     "456",
+		"remote-1": "remote-1",
+		"remote-2": "remote-2",
+	}
+	if len(values) != len(want) {
+		t.Fatalf("expected %d values, got %v", len(want), values)
+	}
+	for key, v := range want {
+		if values[key].String() != v {
+			t.Errorf("expected %s=%s, got %s", key, v, values[key])
+		}
+	}
+	if n := peer.getter.batches.Load(); n != 1 {
+		t.Errorf("expected 1 batch request to peer, got %d", n)
+	}
+	if n := loader.batches.Load(); n != 1 {
+		t.Errorf("expected 1 batch load, got %d", n)
+	}
+
+	group.GetMany(context.Background(), []string{"Tom", "Jack"})
+	if n := loader.batches.Load(); n != 1 {
+		t.Errorf("expected local keys to be cached, got %d batch loads", n)
+	}
+}
+
+func TestHTTPGetter_GetMany(t *testing.T) {
+	NewGroup("http-batch", &batchDB{})
+	srv := httptest.NewServer(&HTTPPool{})
+	defer srv.Close()
+
+	values, err := NewHTTPGetter("test", srv.URL).GetMany(context.Background(), "http-batch", []string{"Tom", "unknown"})
+	if err != nil {
+		t.Fatalf("GetMany failed, err: %v", err)
+	}
+	if len(values) != 1 || string(values["Tom"]) != "123" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+}
+
+func TestGroup_GetManyLocally(t *testing.T) {
+	var (
+		loader = &batchDB{}
+		peer   = &fakePeer{getter: &fakeGetter{}}
+	)
+	NewGroup("batch-local", loader).RegisterPeer(peer)
+	srv := httptest.NewServer(&HTTPPool{})
+	defer srv.Close()
+
+	// 其他节点转发的批量请求在本节点加载, 即使本节点认为 key 属于其他节点也不再转发
+	values, err := NewHTTPGetter("test", srv.URL).GetMany(context.Background(), "batch-local", []string{"Tom", "remote-1"})
+	if err != nil || len(values) != 1 || string(values["Tom"]) != "123" {
+		t.Fatalf("unexpected values: %v %v", values, err)
+	}
+	resp, err := (&GrpcPeer{}).GetMany(context.Background(), &pb.GetManyRequest{Group: "batch-local", Keys: []string{"Jack", "remote-2"}})
+	if err != nil || len(resp.GetValues()) != 1 || string(resp.GetValues()["Jack"]) != "456" {
+		t.Fatalf("unexpected response: %v %v", resp, err)
+	}
+	if n := peer.getter.batches.Load(); n != 0 {
+		t.Fatalf("expected no forwarded batch requests, got %d", n)
+	}
+	if n := loader.batches.Load(); n != 2 {
+		t.Fatalf("expected 2 batch loads, got %d", n)
+	}
+}
+
+func TestGroup_GetManyOwnerError(t *testing.T) {
+	NewGroup("batch-error-owner", GetterFunc(func(key string) ([]byte, error) {
+		if strings.HasSuffix(key, "down") {
+			return nil, errors.New("db down")
+		}
+		return []byte(key), nil
+	}))
+	srv := httptest.NewServer(&HTTPPool{})
+	defer srv.Close()
+
+	// 所有者加载失败的 key 在 HTTP 与 gRPC 上都作为 BatchError 返回, 其余 key 的结果仍然有效
+	var batchErr *BatchError
+	values, err := NewHTTPGetter("owner", srv.URL).GetMany(context.Background(), "batch-error-owner", []string{"Tom", "down"})
+	if !errors.As(err, &batchErr) || len(batchErr.Keys) != 1 || batchErr.Keys[0] != "down" || string(values["Tom"]) != "Tom" {
+		t.Fatalf("expected down to fail over http, got %v %v", values, err)
+	}
+	resp, err := (&GrpcPeer{}).GetMany(context.Background(), &pb.GetManyRequest{Group: "batch-error-owner", Keys: []string{"Jack", "down"}})
+	if err != nil {
+		t.Fatalf("GetMany failed, err: %v", err)
+	}
+	values, err = getManyResult([]string{"Jack", "down"}, resp)
+	if !errors.As(err, &batchErr) || len(batchErr.Keys) != 1 || batchErr.Keys[0] != "down" || string(values["Jack"]) != "Jack" {
+		t.Fatalf("expected down to fail over grpc, got %v %v", values, err)
+	}
+
+	// 非所有者节点的 GetMany 返回所有者的错误, 而不是把失败的 key 当作不存在
+	owner := &groupGetter{HTTPGetter: *NewHTTPGetter("owner", srv.URL), group: "batch-error-owner"}
+	group := NewGroup("batch-error", GetterFunc(func(key string) ([]byte, error) {
+		t.Errorf("key %s should be loaded by the owner", key)
+		return nil, nil
+	}))
+	group.RegisterPeer(&fakePeer{owner: owner})
+	result, err := group.GetMany(context.Background(), []string{"remote-1", "remote-down"})
+	if !errors.As(err, &batchErr) || len(batchErr.Keys) != 1 || batchErr.Keys[0] != "remote-down" {
+		t.Fatalf("expected remote-down to fail, got %v", err)
+	}
+	if len(result) != 1 || result["remote-1"].String() != "remote-1" {
+		t.Fatalf("expected remote-1, got %v", result)
+	}
+	if errs := group.Stats().PeerErrors; errs != 1 {
+		t.Fatalf("expected 1 peer error, got %d", errs)
+	}
+}
@@ -1,7 +1,10 @@
 package goCache
 
 type ByteView struct {
-	b []byte
+	b      []byte
+	soft   int64 // 软过期时间, UnixMilli, 0 表示不启用
+	expire int64 // 过期时间, UnixMilli, 0 表示永不过期
+	loaded bool  // 是否通过 Getter 加载
 }
 
 func (b ByteView) Size() int {
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	arcT1 = iota // 只访问过一次的数据
+	arcT2        // 访问过多次的数据
+	arcB1        // 从 T1 淘汰的幽灵记录
+	arcB2        // 从 T2 淘汰的幽灵记录
+)
+
+// ARC Adaptive Replacement Cache
+// T1/T2 保存缓存数据, B1/B2 只保存被淘汰的 key, 命中幽灵记录时调整 T1 的目标大小 p
+// 容量按字节计算, p 同样为字节数
+type ARC struct {
+	maxBytes  int64                    // 最大缓存大小
+	usedBytes int64                    // 已使用缓存大小(T1+T2)
+	p         int64                    // T1 目标大小
+	lists     [4]*list.List            // T1 T2 B1 B2
+	bytes     [4]int64                 // 各个链表占用大小
+	mp        map[string]*list.Element // key 对 T1/T2 node的映射
+	ghosts    map[string]*list.Element // key 对 B1/B2 node的映射
+	expiry    *expiryHeap              // 过期时间最小堆
+	onEvicted OnEvictedFunc            // 淘汰缓存的回调函数
+	len       int                      // entry 个数
+	evictions int64                    // 淘汰个数
+	mu        sync.Mutex
+}
+
+type ARCEntry struct {
+	entry
+	list int
+}
+
+type arcGhost struct {
+	key  string
+	size int64
+	list int
+}
+
+func NewARC(maxBytes int64, evictedFunc OnEvictedFunc) *ARC {
+	a := &ARC{
+		maxBytes:  maxBytes,
+		mp:        make(map[string]*list.Element),
+		ghosts:    make(map[string]*list.Element),
+		expiry:    newExpiryHeap(),
+		onEvicted: evictedFunc,
+	}
+	for i := range a.lists {
+		a.lists[i] = list.New()
+	}
+	return a
+}
+
+func (A *ARC) Get(key string) (value Value, ok bool) {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+
+	elem, ok := A.mp[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(ARCEntry)
+	if e.expired(time.Now().UnixMilli()) {
+		A.evictions++
+		A.remove(elem).notify(A.onEvicted, EvictedExpired)
+		return nil, false
+	}
+	A.move(elem, arcT2)
+	return e.value, true
+}
+
+func (A *ARC) Set(key string, value Value, expire time.Duration) {
+	newEntry := ARCEntry{
+		entry: makeEntry(key, value, expire),
+	}
+	size := int64(len(key)) + int64(value.Size())
+
+	A.mu.Lock()
+	defer A.mu.Unlock()
+
+	if elem, ok := A.mp[key]; ok {
+		// 命中缓存数据, 移动到 T2
+		old := A.remove(elem)
+		A.push(newEntry, arcT2)
+		old.notify(A.onEvicted, EvictedReplaced)
+		A.evict(false)
+		return
+	}
+
+	inB2 := false
+	target := arcT1
+	if elem, ok := A.ghosts[key]; ok {
+		// 命中幽灵记录, 调整 T1 目标大小
+		g := elem.Value.(arcGhost)
+		switch g.list {
+		case arcB1:
+			A.p = min64(A.p+size*max64(A.bytes[arcB2]/max64(A.bytes[arcB1], 1), 1), A.maxBytes)
+		case arcB2:
+			A.p = max64(A.p-size*max64(A.bytes[arcB1]/max64(A.bytes[arcB2], 1), 1), 0)
+			inB2 = true
+		}
+		A.removeGhost(elem)
+		target = arcT2
+	} else {
+		A.trimGhosts(size)
+	}
+	A.push(newEntry, target)
+	A.evict(inB2)
+}
+
+func (A *ARC) Delete(key string) (value Value, ok bool) {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+
+	if elem, ok := A.mp[key]; ok {
+		e := A.remove(elem)
+		e.notify(A.onEvicted, EvictedDeleted)
+		return e.value, true
+	}
+	return nil, false
+}
+
+func (A *ARC) RemoveOldest() {
+	// WARN 不可以设置锁, 外部已经设置
+	A.replace(false)
+}
+
+// withLock 持有锁执行 fn
+func (A *ARC) withLock(fn func()) {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+	fn()
+}
+
+// RemoveExpired 清理所有已过期的数据, 过期数据不进入幽灵链表
+func (A *ARC) RemoveExpired() int {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+
+	n := 0
+	now := time.Now().UnixMilli()
+	for key, ok := A.expiry.next(now); ok; key, ok = A.expiry.next(now) {
+		A.evictions++
+		A.remove(A.mp[key]).notify(A.onEvicted, EvictedExpired)
+		n++
+	}
+	return n
+}
+
+// Keys 当前所有 key 的快照
+func (A *ARC) Keys() []string {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+	keys := make([]string, 0, len(A.mp))
+	for key := range A.mp {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (A *ARC) Len() int {
+	return A.len
+}
+
+// Stats 获取统计信息
+func (A *ARC) Stats() Stats {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+	return Stats{
+		Bytes:     A.usedBytes,
+		Items:     A.len,
+		Evictions: A.evictions,
+	}
+}
+
+// evict 淘汰缓存数据直到不超过容量
+func (A *ARC) evict(inB2 bool) {
+	for A.maxBytes != 0 && A.usedBytes > A.maxBytes && A.len > 0 {
+		A.replace(inB2)
+	}
+}
+
+// trimGhosts 插入新数据前限制幽灵记录的大小, T1+B1 不超过容量, 总大小不超过两倍容量
+func (A *ARC) trimGhosts(size int64) {
+	if A.maxBytes == 0 {
+		return
+	}
+	for A.bytes[arcT1]+A.bytes[arcB1]+size > A.maxBytes && A.lists[arcB1].Len() > 0 {
+		A.removeGhost(A.lists[arcB1].Front())
+	}
+	for A.usedBytes+A.bytes[arcB1]+A.bytes[arcB2]+size > 2*A.maxBytes && A.lists[arcB2].Len() > 0 {
+		A.removeGhost(A.lists[arcB2].Front())
+	}
+}
+
+// replace T1 超过目标大小时从 T1 淘汰, 否则从 T2 淘汰, 被淘汰的 key 进入对应的幽灵链表
+func (A *ARC) replace(inB2 bool) {
+	var elem *list.Element
+	t1 := A.bytes[arcT1]
+	if t1 > 0 && (t1 > A.p || (inB2 && t1 == A.p) || A.lists[arcT2].Len() == 0) {
+		elem = A.lists[arcT1].Front()
+	} else {
+		elem = A.lists[arcT2].Front()
+	}
+	if elem == nil {
+		return
+	}
+	e := A.remove(elem)
+	A.evictions++
+	ghost := arcGhost{
+		key:  e.key,
+		size: int64(len(e.key)) + int64(e.value.Size()),
+		list: arcB1,
+	}
+	if e.list == arcT2 {
+		ghost.list = arcB2
+	}
+	A.ghosts[e.key] = A.lists[ghost.list].PushBack(ghost)
+	A.bytes[ghost.list] += ghost.size
+	e.notify(A.onEvicted, EvictedCapacity)
+}
+
+func (A *ARC) push(e ARCEntry, target int) {
+	size := int64(len(e.key)) + int64(e.value.Size())
+	e.list = target
+	A.mp[e.key] = A.lists[target].PushBack(e)
+	A.expiry.set(e.key, e.expire)
+	A.bytes[target] += size
+	A.usedBytes += size
+	A.len++
+}
+
+func (A *ARC) move(elem *list.Element, target int) {
+	e := A.remove(elem)
+	A.push(e, target)
+}
+
+func (A *ARC) remove(elem *list.Element) ARCEntry {
+	e := elem.Value.(ARCEntry)
+	size := int64(len(e.key)) + int64(e.value.Size())
+	A.lists[e.list].Remove(elem)
+	delete(A.mp, e.key)
+	A.expiry.remove(e.key)
+	A.bytes[e.list] -= size
+	A.usedBytes -= size
+	A.len--
+	return e
+}
+
+func (A *ARC) removeGhost(elem *list.Element) {
+	g := elem.Value.(arcGhost)
+	A.lists[g.list].Remove(elem)
+	delete(A.ghosts, g.key)
+	A.bytes[g.list] -= g.size
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestARC_GetDelete(t *testing.T) {
+	arc := NewARC(100, nil)
+
+	arc.Set("key1", NewValue("10"), time.Second*20)
+	val, ok := arc.Get("key1")
+	if !ok || val.String() != "10" {
+		t.Errorf("Expected key1=10, got %v", val)
+	}
+
+	val, ok = arc.Delete("key1")
+	if !ok || val.String() != "10" {
+		t.Errorf("Expected key1=10, got %v", val)
+	}
+	val, ok = arc.Get("key1")
+	if ok || val != nil {
+		t.Errorf("Expected key1 to be deleted, got %v", val)
+	}
+}
+
+func TestARC_Eviction(t *testing.T) {
+	// 每个 entry 大小为 6, 容量为 3 个 entry
+	arc := NewARC(18, nil)
+
+	arc.Set("key1", NewValue("10"), 0)
+	arc.Set("key2", NewValue("20"), 0)
+	arc.Set("key3", NewValue("30"), 0)
+	arc.Set("key4", NewValue("40"), 0)
+
+	if _, ok := arc.Get("key1"); ok {
+		t.Errorf("Expected key1 to be evicted")
+	}
+	if arc.usedBytes > arc.maxBytes {
+		t.Errorf("usedBytes %d exceeds maxBytes %d", arc.usedBytes, arc.maxBytes)
+	}
+	if _, ok := arc.ghosts["key1"]; !ok {
+		t.Errorf("Expected key1 to be remembered in B1")
+	}
+}
+
+// TestARC_Adapt 命中 B1 幽灵记录时 T1 目标大小增大, 命中 B2 时减小
+func TestARC_Adapt(t *testing.T) {
+	arc := NewARC(18, nil)
+
+	arc.Set("key1", NewValue("10"), 0)
+	arc.Set("key2", NewValue("20"), 0)
+	arc.Set("key3", NewValue("30"), 0)
+	arc.Set("key4", NewValue("40"), 0)
+
+	arc.Set("key1", NewValue("10"), 0)
+	if arc.p == 0 {
+		t.Fatalf("Expected p to grow after B1 hit")
+	}
+	if _, ok := arc.Get("key1"); !ok {
+		t.Errorf("Expected key1 to be cached in T2")
+	}
+
+	p := arc.p
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("hot-%d", i%3)
+		arc.Set(key, NewValue("00"), 0)
+		arc.Get(key)
+	}
+	for key, elem := range arc.ghosts {
+		if elem.Value.(arcGhost).list == arcB2 {
+			arc.Set(key, NewValue("00"), 0)
+			if arc.p >= p {
+				t.Errorf("Expected p to shrink after B2 hit, got %d", arc.p)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected a B2 ghost")
+}
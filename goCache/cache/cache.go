@@ -8,12 +8,65 @@ type Cache interface {
 	Delete(key string) (value Value, ok bool)          // 删除缓存
 	RemoveOldest()                                     // 淘汰缓存
 	Len() int                                          // 获取缓存记录数量
+	Stats() Stats                                      // 获取统计信息
+}
+
+// Lister 可以列出所有 key 的缓存, 用于集群成员变更后清理不再属于本节点的数据
+type Lister interface {
+	Keys() []string // 当前所有 key 的快照
+}
+
+// locker 可以在内部锁中执行操作的缓存, 用于外部调用要求持有锁的 RemoveOldest
+type locker interface {
+	withLock(fn func())
+}
+
+// Stats 缓存统计信息
+type Stats struct {
+	Bytes     int64 // 已使用缓存大小
+	Items     int   // entry 个数
+	Evictions int64 // 因容量或过期被淘汰的个数
 }
 
 type entry struct {
-	key    string
-	value  Value
-	expire int64 // UnixMilli
+	key     string
+	value   Value
+	expire  int64 // UnixMilli, 0 表示永不过期
+	created int64 // UnixMilli, 写入时间
+}
+
+func makeEntry(key string, value Value, expire time.Duration) entry {
+	return entry{
+		key:     key,
+		value:   value,
+		expire:  expireAt(expire),
+		created: time.Now().UnixMilli(),
+	}
+}
+
+func (e entry) expired(now int64) bool {
+	return e.expire != 0 && now > e.expire
+}
+
+// notify 调用淘汰回调
+func (e entry) notify(fn OnEvictedFunc, reason EvictReason) {
+	if fn == nil {
+		return
+	}
+	fn(EvictEvent{
+		Key:    e.key,
+		Value:  e.value,
+		Reason: reason,
+		Age:    time.Duration(time.Now().UnixMilli()-e.created) * time.Millisecond,
+	})
+}
+
+// expireAt 计算过期时间, expire <= 0 表示永不过期
+func expireAt(expire time.Duration) int64 {
+	if expire <= 0 {
+		return 0
+	}
+	return time.Now().Add(expire).UnixMilli()
 }
 
 type Value interface {
@@ -21,4 +74,37 @@ type Value interface {
 	String() string
 }
 
-type OnEvictedFunc func(key string, value Value)
+// EvictReason 淘汰原因
+type EvictReason int
+
+const (
+	EvictedCapacity EvictReason = iota // 超出容量被淘汰
+	EvictedExpired                     // 过期被清理
+	EvictedDeleted                     // 被 Delete 删除
+	EvictedReplaced                    // 被 Set 覆盖
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedExpired:
+		return "expired"
+	case EvictedDeleted:
+		return "deleted"
+	case EvictedReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictEvent 数据离开缓存的事件
+type EvictEvent struct {
+	Key    string
+	Value  Value
+	Reason EvictReason
+	Age    time.Duration // 数据在缓存中存在的时间
+}
+
+type OnEvictedFunc func(event EvictEvent)
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEvictEvent 所有淘汰策略在容量淘汰、Delete、Set 覆盖时都应触发回调
+func TestEvictEvent(t *testing.T) {
+	factories := map[string]Factory{
+		"lru": newLRUCache,
+		"lfu": func(maxBytes int64, evictedFunc OnEvictedFunc) Cache {
+			return NewLFU(maxBytes, evictedFunc)
+		},
+		"tinylfu": func(maxBytes int64, evictedFunc OnEvictedFunc) Cache {
+			return NewTinyLFU(maxBytes, evictedFunc)
+		},
+		"arc": func(maxBytes int64, evictedFunc OnEvictedFunc) Cache {
+			return NewARC(maxBytes, evictedFunc)
+		},
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			events := make(map[EvictReason][]EvictEvent)
+			// 每个 entry 大小为 6, 容量为 2 个 entry
+			c := factory(12, func(event EvictEvent) {
+				events[event.Reason] = append(events[event.Reason], event)
+			})
+
+			c.Set("key1", NewValue("10"), time.Minute)
+			time.Sleep(time.Millisecond * 5)
+			c.Set("key1", NewValue("11"), time.Minute)
+			c.Delete("key1")
+			c.Set("key2", NewValue("20"), time.Minute)
+			c.Set("key3", NewValue("30"), time.Minute)
+			c.Set("key4", NewValue("40"), time.Minute)
+
+			if e := events[EvictedReplaced]; len(e) != 1 || e[0].Key != "key1" || e[0].Value.String() != "10" || e[0].Age <= 0 {
+				t.Errorf("Expected key1=10 to be replaced, got %+v", e)
+			}
+			if e := events[EvictedDeleted]; len(e) != 1 || e[0].Key != "key1" || e[0].Value.String() != "11" {
+				t.Errorf("Expected key1=11 to be deleted, got %+v", e)
+			}
+			if e := events[EvictedCapacity]; len(e) != 1 {
+				t.Errorf("Expected 1 capacity eviction, got %+v", e)
+			}
+			if stats := c.Stats(); stats != (Stats{Bytes: 12, Items: 2, Evictions: 1}) {
+				t.Errorf("Unexpected stats %+v", stats)
+			}
+		})
+	}
+}
+
+// TestLister 所有淘汰策略与分片缓存都可以列出当前的 key
+func TestLister(t *testing.T) {
+	caches := map[string]Cache{
+		"lru":     NewLRU(0, nil),
+		"lfu":     NewLFU(0, nil),
+		"tinylfu": NewTinyLFU(0, nil),
+		"arc":     NewARC(0, nil),
+		"sharded": NewSharded(4, 0, nil, newLRUCache),
+	}
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"key1", "key2", "key3"} {
+				c.Set(key, NewValue("v"), time.Minute)
+			}
+			c.Delete("key2")
+			keys := c.(Lister).Keys()
+			sort.Strings(keys)
+			if got := strings.Join(keys, ","); got != "key1,key3" {
+				t.Fatalf("expected key1,key3, got %s", got)
+			}
+		})
+	}
+}
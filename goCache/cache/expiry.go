@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Expirer 支持主动清理过期数据的缓存
+type Expirer interface {
+	RemoveExpired() int // 清理已过期的数据, 返回清理个数
+}
+
+// expiryHeap 按过期时间排序的最小堆, 用于快速找到已过期的 key
+type expiryHeap struct {
+	items []*expiryItem
+	mp    map[string]*expiryItem
+}
+
+type expiryItem struct {
+	key    string
+	expire int64 // UnixMilli
+	index  int
+}
+
+func newExpiryHeap() *expiryHeap {
+	return &expiryHeap{
+		mp: make(map[string]*expiryItem),
+	}
+}
+
+// set 设置 key 的过期时间, expire 为 0 表示永不过期
+func (h *expiryHeap) set(key string, expire int64) {
+	if expire == 0 {
+		h.remove(key)
+		return
+	}
+	if item, ok := h.mp[key]; ok {
+		item.expire = expire
+		heap.Fix(h, item.index)
+		return
+	}
+	item := &expiryItem{key: key, expire: expire}
+	h.mp[key] = item
+	heap.Push(h, item)
+}
+
+func (h *expiryHeap) remove(key string) {
+	if item, ok := h.mp[key]; ok {
+		heap.Remove(h, item.index)
+	}
+}
+
+// next 返回一个在 now 之前过期的 key
+func (h *expiryHeap) next(now int64) (string, bool) {
+	if len(h.items) == 0 || h.items[0].expire >= now {
+		return "", false
+	}
+	return h.items[0].key, true
+}
+
+func (h *expiryHeap) Len() int { return len(h.items) }
+
+func (h *expiryHeap) Less(i, j int) bool { return h.items[i].expire < h.items[j].expire }
+
+func (h *expiryHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*expiryItem)
+	item.index = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	delete(h.mp, item.key)
+	return item
+}
+
+// Janitor 定期清理缓存中已过期的数据
+type Janitor struct {
+	interval time.Duration
+	stop     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewJanitor 启动后台清理, 每隔 interval 调用一次 RemoveExpired
+func NewJanitor(c Expirer, interval time.Duration) *Janitor {
+	j := &Janitor{
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+	j.wg.Add(1)
+	go j.run(c)
+	return j
+}
+
+func (j *Janitor) run(c Expirer) {
+	defer j.wg.Done()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.RemoveExpired()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// Close 停止后台清理
+func (j *Janitor) Close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	j.wg.Wait()
+}
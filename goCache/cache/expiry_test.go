@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRU_RemoveExpired(t *testing.T) {
+	var reasons []EvictReason
+	lru := NewLRU(100, func(event EvictEvent) {
+		reasons = append(reasons, event.Reason)
+	})
+
+	lru.Set("key1", NewValue("10"), time.Millisecond)
+	lru.Set("key2", NewValue("20"), time.Millisecond)
+	lru.Set("key3", NewValue("30"), 0)
+	time.Sleep(time.Millisecond * 10)
+
+	if n := lru.RemoveExpired(); n != 2 {
+		t.Errorf("Expected 2 expired entries, got %d", n)
+	}
+	if lru.Len() != 1 || lru.usedBytes != 6 {
+		t.Errorf("Expected only key3 left, len %d usedBytes %d", lru.Len(), lru.usedBytes)
+	}
+	for _, reason := range reasons {
+		if reason != EvictedExpired {
+			t.Errorf("Expected reason expired, got %s", reason)
+		}
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		expired []string
+	)
+	caches := []Cache{
+		NewLRU(100, nil),
+		NewLFU(100, nil),
+		NewTinyLFU(100, nil),
+		NewARC(100, nil),
+	}
+	for _, c := range caches {
+		c.Set("key1", NewValue("10"), time.Millisecond)
+	}
+	sharded := NewSharded(2, 100, func(event EvictEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		expired = append(expired, event.Key)
+	}, newLRUCache)
+	sharded.Set("key1", NewValue("10"), time.Millisecond)
+	caches = append(caches, sharded)
+
+	var janitors []*Janitor
+	for _, c := range caches {
+		janitors = append(janitors, NewJanitor(c.(Expirer), time.Millisecond*5))
+	}
+	time.Sleep(time.Millisecond * 50)
+	for _, j := range janitors {
+		j.Close()
+	}
+
+	for _, c := range caches {
+		if c.Len() != 0 {
+			t.Errorf("Expected %T to be swept, len %d", c, c.Len())
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 1 || expired[0] != "key1" {
+		t.Errorf("Expected key1 to be reported as expired, got %v", expired)
+	}
+}
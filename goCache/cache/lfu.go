@@ -11,10 +11,12 @@ type LFU struct {
 	mp         map[string]*list.Element
 	mp2        map[string]int64
 	curMinFreq int64
+	expiry     *expiryHeap   // 过期时间最小堆
 	maxBytes   int64         // 最大缓存大小
 	usedBytes  int64         // 已使用缓存大小
 	onEvicted  OnEvictedFunc // 淘汰缓存的回调函数
 	len        int           // entry 个数
+	evictions  int64         // 淘汰个数
 	mu         sync.Mutex
 }
 
@@ -26,6 +28,7 @@ func NewLFU(maxBytes int64, evictedFunc OnEvictedFunc) *LFU {
 		curMinFreq: 1,
 		mp:         make(map[string]*list.Element),
 		mp2:        make(map[string]int64),
+		expiry:     newExpiryHeap(),
 		onEvicted:  evictedFunc,
 	}
 	return l
@@ -41,6 +44,10 @@ func (L *LFU) Get(key string) (value Value, ok bool) {
 	defer L.mu.Unlock()
 	if elem, ok := L.mp[key]; ok {
 		entry := elem.Value.(LFUEntry)
+		if entry.expired(time.Now().UnixMilli()) {
+			L.evict(elem, EvictedExpired)
+			return nil, false
+		}
 		L.incr(entry)
 		return entry.value, true
 	}
@@ -51,25 +58,26 @@ func (L *LFU) Set(key string, value Value, expire time.Duration) {
 	L.mu.Lock()
 	defer L.mu.Unlock()
 	if elem, ok := L.mp[key]; ok {
-		entry := elem.Value.(LFUEntry)
-		oldSize := entry.value.Size()
-		entry.value = value
-		entry.expire = int64(expire)
+		oldEntry := elem.Value.(LFUEntry)
+		entry := oldEntry
+		entry.entry = makeEntry(key, value, expire)
 		L.incr(entry)
-		L.usedBytes += int64(value.Size()) - int64(oldSize)
+		L.usedBytes += int64(value.Size()) - int64(oldEntry.value.Size())
+		L.expiry.set(key, entry.expire)
+		oldEntry.notify(L.onEvicted, EvictedReplaced)
 	} else {
 		entry := LFUEntry{
-			entry: entry{
-				key:    key,
-				value:  value,
-				expire: int64(expire),
-			},
-			freq: 1,
+			entry: makeEntry(key, value, expire),
+			freq:  1,
 		}
 		L.add(entry)
+		L.curMinFreq = 1
+		L.usedBytes += int64(len(entry.key)) + int64(entry.value.Size())
+		L.len++
+		L.expiry.set(key, entry.expire)
 	}
 
-	for L.maxBytes != 0 && L.usedBytes > L.maxBytes {
+	for L.maxBytes != 0 && L.usedBytes > L.maxBytes && L.len > 0 {
 		L.RemoveOldest()
 	}
 }
@@ -93,47 +101,96 @@ func (L *LFU) add(entry LFUEntry) {
 	}
 	L.mp[entry.key] = L.freq[entry.freq].PushBack(entry)
 	L.mp2[entry.key] = entry.freq
-	L.usedBytes += int64(len(entry.key)) + int64(entry.value.Size())
 }
 
 func (L *LFU) Delete(key string) (value Value, ok bool) {
 	L.mu.Lock()
 	defer L.mu.Unlock()
-	if freq, ok := L.mp2[key]; ok {
-		l := L.freq[freq]
-		elem := L.mp[key]
-		l.Remove(elem)
-		delete(L.mp, key)
-		delete(L.mp2, key)
+	if elem, ok := L.mp[key]; ok {
+		entry := L.remove(elem)
+		entry.notify(L.onEvicted, EvictedDeleted)
+		return entry.value, true
 	}
-	return nil, true
+	return nil, false
 }
 
 func (L *LFU) RemoveOldest() {
-	l := L.freq[L.curMinFreq]
-	elem := l.Front()
-	entry := elem.Value.(LFUEntry)
-	l.Remove(elem)
-	delete(L.mp, entry.key)
-	delete(L.mp2, entry.key)
-	L.usedBytes -= int64(len(entry.key)) + int64(entry.value.Size())
-	if L.onEvicted != nil {
-		L.onEvicted(entry.key, entry.value)
+	// WARN 不可以设置锁, 外部已经设置
+	if L.len == 0 {
+		return
 	}
-	for tmpl, ok := L.freq[L.curMinFreq]; ; tmpl, ok = L.freq[L.curMinFreq] {
-		if !ok {
-			L.curMinFreq++
-			continue
+	for {
+		if l, ok := L.freq[L.curMinFreq]; ok && l.Len() > 0 {
+			L.evict(l.Front(), EvictedCapacity)
+			return
 		}
-		if tmpl.Len() == 0 {
-			delete(L.freq, L.curMinFreq)
-			L.curMinFreq++
-			continue
-		}
-		break
+		L.curMinFreq++
+	}
+}
+
+// withLock 持有锁执行 fn
+func (L *LFU) withLock(fn func()) {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	fn()
+}
+
+// RemoveExpired 清理所有已过期的数据
+func (L *LFU) RemoveExpired() int {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+
+	n := 0
+	now := time.Now().UnixMilli()
+	for key, ok := L.expiry.next(now); ok; key, ok = L.expiry.next(now) {
+		L.evict(L.mp[key], EvictedExpired)
+		n++
+	}
+	return n
+}
+
+// Keys 当前所有 key 的快照
+func (L *LFU) Keys() []string {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	keys := make([]string, 0, len(L.mp))
+	for key := range L.mp {
+		keys = append(keys, key)
 	}
+	return keys
 }
 
 func (L *LFU) Len() int {
 	return L.len
 }
+
+// Stats 获取统计信息
+func (L *LFU) Stats() Stats {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	return Stats{
+		Bytes:     L.usedBytes,
+		Items:     L.len,
+		Evictions: L.evictions,
+	}
+}
+
+func (L *LFU) evict(elem *list.Element, reason EvictReason) {
+	L.evictions++
+	L.remove(elem).notify(L.onEvicted, reason)
+}
+
+func (L *LFU) remove(elem *list.Element) LFUEntry {
+	entry := elem.Value.(LFUEntry)
+	l := L.freq[entry.freq]
+	l.Remove(elem)
+	if l.Len() == 0 {
+		delete(L.freq, entry.freq)
+	}
+	delete(L.mp, entry.key)
+	delete(L.mp2, entry.key)
+	L.expiry.remove(entry.key)
+	L.usedBytes -= int64(len(entry.key)) + int64(entry.value.Size())
+	L.len--
+	return entry
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func TestLFUCache(t *testing.T) {
-	lfu := NewLFU(1<<32, nil) // 替换为你的 LFU 缓存初始化方式，传入合适的最大缓存大小
+	lfu := NewLFU(40, nil) // 每个 entry 大小为 10, 最多容纳 4 个
 
 	// 添加缓存条目
 	lfu.Set("key1", NewValue("value1"), time.Second)
@@ -44,8 +44,8 @@ func TestLFUCacheEvictionCallback(t *testing.T) {
 	for i := range keys {
 		totalSize += len(keys[i]) + len(values[i])
 	}
-	lru := NewLFU(int64(totalSize-5), func(key string, value Value) {
-		t.Logf("evicted key:%s value:%s", key, value)
+	lru := NewLFU(int64(totalSize-5), func(event EvictEvent) {
+		t.Logf("evicted key:%s value:%s reason:%s", event.Key, event.Value, event.Reason)
 	})
 
 	// Set values that will exceed the cache capacity
@@ -11,8 +11,10 @@ type LRU struct {
 	usedBytes int64                    // 已使用缓存大小
 	ll        *list.List               // 底层链表
 	mp        map[string]*list.Element // key 对 底层链表node的映射
+	expiry    *expiryHeap              // 过期时间最小堆
 	onEvicted OnEvictedFunc            // 淘汰缓存的回调函数
 	len       int                      // entry 个数
+	evictions int64                    // 淘汰个数
 	mu        sync.Mutex
 }
 
@@ -26,6 +28,7 @@ func NewLRU(maxBytes int64, evictedFunc OnEvictedFunc) *LRU {
 		usedBytes: 0,
 		ll:        list.New(),
 		mp:        make(map[string]*list.Element),
+		expiry:    newExpiryHeap(),
 		onEvicted: evictedFunc,
 	}
 	return l
@@ -37,10 +40,8 @@ func (L *LRU) Get(key string) (value Value, ok bool) {
 
 	if elem, ok := L.mp[key]; ok {
 		entry := elem.Value.(LRUEntry)
-		if entry.expire != 0 && time.Now().UnixMilli() > entry.expire {
-			L.ll.Remove(elem)
-			delete(L.mp, entry.key)
-			L.len--
+		if entry.expired(time.Now().UnixMilli()) {
+			L.evict(elem, EvictedExpired)
 			return nil, false
 		}
 		L.ll.MoveToBack(elem)
@@ -51,29 +52,27 @@ func (L *LRU) Get(key string) (value Value, ok bool) {
 
 func (L *LRU) Set(key string, value Value, expire time.Duration) {
 	newEntry := LRUEntry{
-		entry: entry{
-			key:    key,
-			value:  value,
-			expire: time.Now().Add(expire).UnixMilli(),
-		},
+		entry: makeEntry(key, value, expire),
 	}
 
 	L.mu.Lock()
 	defer L.mu.Unlock()
 
 	if elem, ok := L.mp[key]; ok {
-		L.ll.Remove(elem)
-		oldEntry := elem.Value.(entry)
-		L.usedBytes += int64(oldEntry.value.Size()) - int64(value.Size())
+		L.ll.MoveToBack(elem)
+		oldEntry := elem.Value.(LRUEntry)
+		L.usedBytes += int64(value.Size()) - int64(oldEntry.value.Size())
 		elem.Value = newEntry
+		oldEntry.notify(L.onEvicted, EvictedReplaced)
 	} else {
 		elem = L.ll.PushBack(newEntry)
 		L.mp[key] = elem
 		L.usedBytes += int64(len(key)) + int64(value.Size())
 		L.len++
 	}
+	L.expiry.set(key, newEntry.expire)
 
-	for L.maxBytes != 0 && L.usedBytes > L.maxBytes {
+	for L.maxBytes != 0 && L.usedBytes > L.maxBytes && L.len > 0 {
 		L.RemoveOldest()
 	}
 }
@@ -83,26 +82,78 @@ func (L *LRU) Delete(key string) (value Value, ok bool) {
 	defer L.mu.Unlock()
 
 	if elem, ok := L.mp[key]; ok {
-		L.ll.Remove(elem)
-		delete(L.mp, key)
-		return elem.Value.(entry).value, true
+		e := L.remove(elem)
+		e.notify(L.onEvicted, EvictedDeleted)
+		return e.value, true
 	}
 	return nil, false
 }
 
 func (L *LRU) RemoveOldest() {
 	// WARN 不可以设置锁, 外部已经设置
-	f := L.ll.Front()
-	e := f.Value.(entry)
-	L.ll.Remove(f)
-	delete(L.mp, e.key)
-	L.usedBytes -= int64(len(e.key)) + int64(e.value.Size())
-	L.len--
-	if L.onEvicted != nil {
-		L.onEvicted(e.key, e.value)
+	if f := L.ll.Front(); f != nil {
+		L.evict(f, EvictedCapacity)
+	}
+}
+
+// withLock 持有锁执行 fn
+func (L *LRU) withLock(fn func()) {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	fn()
+}
+
+// RemoveExpired 清理所有已过期的数据
+func (L *LRU) RemoveExpired() int {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+
+	n := 0
+	now := time.Now().UnixMilli()
+	for key, ok := L.expiry.next(now); ok; key, ok = L.expiry.next(now) {
+		L.evict(L.mp[key], EvictedExpired)
+		n++
 	}
+	return n
+}
+
+// Keys 当前所有 key 的快照
+func (L *LRU) Keys() []string {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	keys := make([]string, 0, len(L.mp))
+	for key := range L.mp {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 func (L *LRU) Len() int {
 	return L.len
 }
+
+// Stats 获取统计信息
+func (L *LRU) Stats() Stats {
+	L.mu.Lock()
+	defer L.mu.Unlock()
+	return Stats{
+		Bytes:     L.usedBytes,
+		Items:     L.len,
+		Evictions: L.evictions,
+	}
+}
+
+func (L *LRU) evict(elem *list.Element, reason EvictReason) {
+	L.evictions++
+	L.remove(elem).notify(L.onEvicted, reason)
+}
+
+func (L *LRU) remove(elem *list.Element) LRUEntry {
+	e := elem.Value.(LRUEntry)
+	L.ll.Remove(elem)
+	delete(L.mp, e.key)
+	L.expiry.remove(e.key)
+	L.usedBytes -= int64(len(e.key)) + int64(e.value.Size())
+	L.len--
+	return e
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultShards = 16
+)
+
+// Factory 缓存构造函数, 用于创建分片内部的缓存
+type Factory func(maxBytes int64, evictedFunc OnEvictedFunc) Cache
+
+// Sharded 分片缓存, 按 key 的 hash 分散到多个互相独立的内部缓存, 每个分片各自加锁
+type Sharded struct {
+	shards []Cache // 内部缓存
+	next   uint32  // RemoveOldest 轮询位置
+}
+
+// NewSharded 创建分片缓存, 每个分片的最大大小为 maxBytes/shards, maxBytes 不为 0 时至少为 1
+func NewSharded(shards int, maxBytes int64, evictedFunc OnEvictedFunc, factory Factory) *Sharded {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	s := &Sharded{
+		shards: make([]Cache, shards),
+	}
+	// 0 表示不限制大小, 不能因为整除截断为 0
+	shardBytes := maxBytes / int64(shards)
+	if maxBytes > 0 && shardBytes == 0 {
+		shardBytes = 1
+	}
+	for i := range s.shards {
+		s.shards[i] = factory(shardBytes, evictedFunc)
+	}
+	return s
+}
+
+func (s *Sharded) Get(key string) (value Value, ok bool) {
+	return s.shard(key).Get(key)
+}
+
+func (s *Sharded) Set(key string, value Value, expire time.Duration) {
+	s.shard(key).Set(key, value, expire)
+}
+
+func (s *Sharded) Delete(key string) (value Value, ok bool) {
+	return s.shard(key).Delete(key)
+}
+
+// RemoveOldest 轮询各个分片进行淘汰, 在分片的锁内调用其 RemoveOldest
+func (s *Sharded) RemoveOldest() {
+	for range s.shards {
+		i := atomic.AddUint32(&s.next, 1) % uint32(len(s.shards))
+		shard, removed := s.shards[i], false
+		evict := func() {
+			if shard.Len() > 0 {
+				shard.RemoveOldest()
+				removed = true
+			}
+		}
+		if l, ok := shard.(locker); ok {
+			l.withLock(evict)
+		} else {
+			evict()
+		}
+		if removed {
+			return
+		}
+	}
+}
+
+// RemoveExpired 清理所有分片中已过期的数据
+func (s *Sharded) RemoveExpired() int {
+	n := 0
+	for _, c := range s.shards {
+		if e, ok := c.(Expirer); ok {
+			n += e.RemoveExpired()
+		}
+	}
+	return n
+}
+
+// Keys 汇总所有分片的 key, 内部缓存未实现 Lister 时忽略该分片
+func (s *Sharded) Keys() []string {
+	var keys []string
+	for _, c := range s.shards {
+		if l, ok := c.(Lister); ok {
+			keys = append(keys, l.Keys()...)
+		}
+	}
+	return keys
+}
+
+func (s *Sharded) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// Stats 汇总所有分片的统计信息
+func (s *Sharded) Stats() Stats {
+	var stats Stats
+	for _, c := range s.shards {
+		st := c.Stats()
+		stats.Bytes += st.Bytes
+		stats.Items += st.Items
+		stats.Evictions += st.Evictions
+	}
+	return stats
+}
+
+func (s *Sharded) shard(key string) Cache {
+	// fnv-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return s.shards[h%uint32(len(s.shards))]
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newLRUCache(maxBytes int64, evictedFunc OnEvictedFunc) Cache {
+	return NewLRU(maxBytes, evictedFunc)
+}
+
+func TestSharded(t *testing.T) {
+	s := NewSharded(4, 1<<20, nil, newLRUCache)
+
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("key%d", i), NewValue("10"), time.Second*20)
+	}
+	if s.Len() != 100 {
+		t.Errorf("Expected len 100, got %d", s.Len())
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if val, ok := s.Get(key); !ok || val.String() != "10" {
+			t.Errorf("Expected %s=10, got %v", key, val)
+		}
+	}
+
+	used := 0
+	for _, shard := range s.shards {
+		if shard.Len() > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("Expected keys to spread across shards, used %d", used)
+	}
+}
+
+func TestSharded_MaxBytes(t *testing.T) {
+	s := NewSharded(4, 400, nil, newLRUCache)
+	for _, shard := range s.shards {
+		if shard.(*LRU).maxBytes != 100 {
+			t.Errorf("Expected shard maxBytes 100, got %d", shard.(*LRU).maxBytes)
+		}
+	}
+}
+
+func TestSharded_SmallMaxBytes(t *testing.T) {
+	// maxBytes 小于分片数时每个分片仍然有上限
+	s := NewSharded(16, 8, nil, newLRUCache)
+	for _, shard := range s.shards {
+		if shard.(*LRU).maxBytes != 1 {
+			t.Errorf("Expected shard maxBytes 1, got %d", shard.(*LRU).maxBytes)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("key%d", i), NewValue("10"), time.Second*20)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected entries to be evicted, got len %d", s.Len())
+	}
+}
+
+func TestSharded_RemoveOldest(t *testing.T) {
+	s := NewSharded(4, 0, nil, newLRUCache)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			s.Set(fmt.Sprintf("key%d", i), NewValue("10"), time.Second*20)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		s.RemoveOldest()
+	}
+	<-done
+	for s.Len() > 0 {
+		s.RemoveOldest()
+	}
+}
+
+func benchmarkParallelGet(b *testing.B, c Cache) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		c.Set(keys[i], NewValue("value"), time.Hour)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkLRU_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, NewLRU(0, nil))
+}
+
+func BenchmarkSharded_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, NewSharded(32, 0, nil, newLRUCache))
+}
+
+func BenchmarkShardedTinyLFU_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, NewSharded(32, 0, nil, func(maxBytes int64, evictedFunc OnEvictedFunc) Cache {
+		return NewTinyLFU(maxBytes, evictedFunc)
+	}))
+}
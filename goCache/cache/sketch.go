@@ -0,0 +1,84 @@
+package cache
+
+import "hash/fnv"
+
+const (
+	sketchDepth      = 4       // count-min sketch 行数
+	sketchMaxCounter = 15      // 计数器上限
+	sketchMinWidth   = 1 << 8  // 最小列数
+	sketchMaxWidth   = 1 << 20 // 最大列数, 保证频率元数据内存有界
+)
+
+// cmSketch count-min 频率估计, 每 sampleSize 次累加后所有计数减半(老化)
+type cmSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newCMSketch(width int) *cmSketch {
+	if width < sketchMinWidth {
+		width = sketchMinWidth
+	}
+	if width > sketchMaxWidth {
+		width = sketchMaxWidth
+	}
+	w := sketchMinWidth
+	for w < width {
+		w <<= 1
+	}
+	s := &cmSketch{
+		mask:       uint64(w - 1),
+		sampleSize: 10 * w,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+func (s *cmSketch) index(key string) (h1, h2 uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, sum>>32 | 1
+}
+
+// Increment 记录一次访问
+func (s *cmSketch) Increment(key string) {
+	h1, h2 := s.index(key)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < sketchMaxCounter {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// Estimate 估计访问频率
+func (s *cmSketch) Estimate(key string) int {
+	h1, h2 := s.index(key)
+	min := uint8(sketchMaxCounter)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < min {
+			min = s.rows[i][idx]
+		}
+	}
+	return int(min)
+}
+
+// reset 老化, 所有计数减半, 使旧的热度逐渐被遗忘
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	segmentWindow    = iota // 准入窗口
+	segmentProbation        // 主缓存试用区
+	segmentProtected        // 主缓存保护区
+)
+
+// TinyLFU Window-TinyLFU
+// 新数据先进入窗口 LRU, 被挤出窗口后与主缓存(分段 LRU)的淘汰候选比较 count-min sketch 频率, 频率更高者留下
+type TinyLFU struct {
+	maxBytes       int64                    // 最大缓存大小
+	usedBytes      int64                    // 已使用缓存大小
+	maxWindow      int64                    // 窗口最大大小
+	windowBytes    int64                    // 窗口已使用大小
+	maxProtected   int64                    // 保护区最大大小
+	protectedBytes int64                    // 保护区已使用大小
+	window         *list.List               // 窗口 LRU
+	probation      *list.List               // 试用区 LRU
+	protected      *list.List               // 保护区 LRU
+	mp             map[string]*list.Element // key 对 底层链表node的映射
+	expiry         *expiryHeap              // 过期时间最小堆
+	sketch         *cmSketch                // 频率估计
+	onEvicted      OnEvictedFunc            // 淘汰缓存的回调函数
+	len            int                      // entry 个数
+	evictions      int64                    // 淘汰个数
+	mu             sync.Mutex
+}
+
+type TinyLFUEntry struct {
+	entry
+	segment int
+}
+
+func NewTinyLFU(maxBytes int64, evictedFunc OnEvictedFunc) *TinyLFU {
+	maxWindow := maxBytes / 100
+	if maxWindow == 0 {
+		maxWindow = 1
+	}
+	width := 1 << 16
+	if maxBytes != 0 {
+		width = int(maxBytes / 32)
+	}
+	return &TinyLFU{
+		maxBytes:     maxBytes,
+		maxWindow:    maxWindow,
+		maxProtected: (maxBytes - maxWindow) * 8 / 10,
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		mp:           make(map[string]*list.Element),
+		expiry:       newExpiryHeap(),
+		sketch:       newCMSketch(width),
+		onEvicted:    evictedFunc,
+	}
+}
+
+func (T *TinyLFU) Get(key string) (value Value, ok bool) {
+	T.mu.Lock()
+	defer T.mu.Unlock()
+
+	T.sketch.Increment(key)
+	elem, ok := T.mp[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(TinyLFUEntry)
+	if e.expired(time.Now().UnixMilli()) {
+		T.evict(elem, EvictedExpired)
+		return nil, false
+	}
+	T.touch(elem)
+	return e.value, true
+}
+
+func (T *TinyLFU) Set(key string, value Value, expire time.Duration) {
+	newEntry := makeEntry(key, value, expire)
+
+	T.mu.Lock()
+	defer T.mu.Unlock()
+
+	T.sketch.Increment(key)
+	if elem, ok := T.mp[key]; ok {
+		e := elem.Value.(TinyLFUEntry)
+		old := e.entry
+		T.grow(e.segment, int64(value.Size())-int64(old.value.Size()))
+		e.entry = newEntry
+		elem.Value = e
+		T.touch(elem)
+		old.notify(T.onEvicted, EvictedReplaced)
+	} else {
+		e := TinyLFUEntry{
+			entry:   newEntry,
+			segment: segmentWindow,
+		}
+		T.mp[key] = T.window.PushBack(e)
+		T.grow(segmentWindow, int64(len(key))+int64(value.Size()))
+		T.len++
+	}
+	T.expiry.set(key, newEntry.expire)
+
+	if T.maxBytes == 0 {
+		return
+	}
+	T.admit()
+	for T.usedBytes > T.maxBytes && T.len > 0 {
+		T.RemoveOldest()
+	}
+}
+
+func (T *TinyLFU) Delete(key string) (value Value, ok bool) {
+	T.mu.Lock()
+	defer T.mu.Unlock()
+
+	if elem, ok := T.mp[key]; ok {
+		e := T.remove(elem)
+		e.notify(T.onEvicted, EvictedDeleted)
+		return e.value, true
+	}
+	return nil, false
+}
+
+// RemoveOldest 依次从试用区、保护区、窗口中淘汰最久未使用的数据
+func (T *TinyLFU) RemoveOldest() {
+	// WARN 不可以设置锁, 外部已经设置
+	elem := T.victim()
+	if elem == nil {
+		elem = T.window.Front()
+	}
+	if elem == nil {
+		return
+	}
+	T.evict(elem, EvictedCapacity)
+}
+
+// withLock 持有锁执行 fn
+func (T *TinyLFU) withLock(fn func()) {
+	T.mu.Lock()
+	defer T.mu.Unlock()
+	fn()
+}
+
+// RemoveExpired 清理所有已过期的数据
+func (T *TinyLFU) RemoveExpired() int {
+	T.mu.Lock()
+	defer T.mu.Unlock()
+
+	n := 0
+	now := time.Now().UnixMilli()
+	for key, ok := T.expiry.next(now); ok; key, ok = T.expiry.next(now) {
+		T.evict(T.mp[key], EvictedExpired)
+		n++
+	}
+	return n
+}
+
+// Keys 当前所有 key 的快照
+func (T *TinyLFU) Keys() []string {
+	T.mu.Lock()
+	defer T.mu.Unlock()
+	keys := make([]string, 0, len(T.mp))
+	for key := range T.mp {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (T *TinyLFU) Len() int {
+	return T.len
+}
+
+// admit 将超出窗口的数据移入主缓存, 主缓存已满时与淘汰候选比较频率
+func (T *TinyLFU) admit() {
+	for T.windowBytes > T.maxWindow {
+		candidate := T.move(T.window.Front(), segmentProbation)
+		for T.usedBytes > T.maxBytes {
+			victim := T.victim()
+			if victim == candidate {
+				// 候选者是试用区中唯一的数据, 与保护区比较
+				victim = T.protected.Front()
+			}
+			if victim == nil || T.frequency(candidate) <= T.frequency(victim) {
+				T.evict(candidate, EvictedCapacity)
+				break
+			}
+			T.evict(victim, EvictedCapacity)
+		}
+	}
+}
+
+// touch 命中后调整数据所在分段
+func (T *TinyLFU) touch(elem *list.Element) {
+	e := elem.Value.(TinyLFUEntry)
+	switch e.segment {
+	case segmentWindow:
+		T.window.MoveToBack(elem)
+	case segmentProbation:
+		T.move(elem, segmentProtected)
+		for T.maxBytes != 0 && T.protectedBytes > T.maxProtected && T.protected.Len() > 1 {
+			T.move(T.protected.Front(), segmentProbation)
+		}
+	case segmentProtected:
+		T.protected.MoveToBack(elem)
+	}
+}
+
+// move 将数据移动到指定分段的队尾
+func (T *TinyLFU) move(elem *list.Element, segment int) *list.Element {
+	e := elem.Value.(TinyLFUEntry)
+	size := int64(len(e.key)) + int64(e.value.Size())
+	T.segmentList(e.segment).Remove(elem)
+	T.grow(e.segment, -size)
+	e.segment = segment
+	moved := T.segmentList(segment).PushBack(e)
+	T.mp[e.key] = moved
+	T.grow(segment, size)
+	return moved
+}
+
+func (T *TinyLFU) victim() *list.Element {
+	if elem := T.probation.Front(); elem != nil {
+		return elem
+	}
+	return T.protected.Front()
+}
+
+func (T *TinyLFU) frequency(elem *list.Element) int {
+	return T.sketch.Estimate(elem.Value.(TinyLFUEntry).key)
+}
+
+// Stats 获取统计信息
+func (T *TinyLFU) Stats() Stats {
+	T.mu.Lock()
+	defer T.mu.Unlock()
+	return Stats{
+		Bytes:     T.usedBytes,
+		Items:     T.len,
+		Evictions: T.evictions,
+	}
+}
+
+func (T *TinyLFU) evict(elem *list.Element, reason EvictReason) {
+	T.evictions++
+	T.remove(elem).notify(T.onEvicted, reason)
+}
+
+func (T *TinyLFU) remove(elem *list.Element) TinyLFUEntry {
+	e := elem.Value.(TinyLFUEntry)
+	T.segmentList(e.segment).Remove(elem)
+	delete(T.mp, e.key)
+	T.expiry.remove(e.key)
+	T.grow(e.segment, -(int64(len(e.key)) + int64(e.value.Size())))
+	T.len--
+	return e
+}
+
+// grow 调整已使用大小
+func (T *TinyLFU) grow(segment int, delta int64) {
+	T.usedBytes += delta
+	switch segment {
+	case segmentWindow:
+		T.windowBytes += delta
+	case segmentProtected:
+		T.protectedBytes += delta
+	}
+}
+
+func (T *TinyLFU) segmentList(segment int) *list.List {
+	switch segment {
+	case segmentWindow:
+		return T.window
+	case segmentProbation:
+		return T.probation
+	default:
+		return T.protected
+	}
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTinyLFU_GetDelete(t *testing.T) {
+	tlfu := NewTinyLFU(100, nil)
+
+	tlfu.Set("key1", NewValue("10"), time.Second*20)
+	val, ok := tlfu.Get("key1")
+	if !ok || val.String() != "10" {
+		t.Errorf("Expected key1=10, got %v", val)
+	}
+
+	val, ok = tlfu.Delete("key1")
+	if !ok || val.String() != "10" {
+		t.Errorf("Expected key1=10, got %v", val)
+	}
+	val, ok = tlfu.Get("key1")
+	if ok || val != nil {
+		t.Errorf("Expected key1 to be deleted, got %v", val)
+	}
+	if tlfu.Len() != 0 {
+		t.Errorf("Expected len 0, got %d", tlfu.Len())
+	}
+}
+
+func TestTinyLFU_Expire(t *testing.T) {
+	tlfu := NewTinyLFU(100, nil)
+
+	tlfu.Set("key1", NewValue("10"), time.Millisecond)
+	time.Sleep(time.Millisecond * 10)
+
+	val, ok := tlfu.Get("key1")
+	if ok || val != nil {
+		t.Errorf("Expected key1 to be expired, got %v", val)
+	}
+}
+
+// TestTinyLFU_ScanResistance 大量只访问一次的 key 不应冲掉高频 key
+func TestTinyLFU_ScanResistance(t *testing.T) {
+	const hotKeys = 10
+	var evicted int
+	tlfu := NewTinyLFU(1000, func(event EvictEvent) {
+		evicted++
+	})
+
+	for round := 0; round < 5; round++ {
+		for i := 0; i < hotKeys; i++ {
+			key := fmt.Sprintf("hot-%d", i)
+			if _, ok := tlfu.Get(key); !ok {
+				tlfu.Set(key, NewValue("0123456789"), 0)
+			}
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		tlfu.Set(fmt.Sprintf("scan-%d", i), NewValue("0123456789"), 0)
+	}
+
+	for i := 0; i < hotKeys; i++ {
+		key := fmt.Sprintf("hot-%d", i)
+		if _, ok := tlfu.Get(key); !ok {
+			t.Errorf("Expected %s to survive the scan", key)
+		}
+	}
+	if evicted == 0 {
+		t.Errorf("Expected scan keys to be evicted")
+	}
+	if tlfu.usedBytes > tlfu.maxBytes {
+		t.Errorf("usedBytes %d exceeds maxBytes %d", tlfu.usedBytes, tlfu.maxBytes)
+	}
+}
+
+func TestCMSketch_Reset(t *testing.T) {
+	s := newCMSketch(0)
+	for i := 0; i < 10; i++ {
+		s.Increment("key1")
+	}
+	if s.Estimate("key1") != 10 {
+		t.Errorf("Expected estimate 10, got %d", s.Estimate("key1"))
+	}
+	for i := 0; i < s.sampleSize; i++ {
+		s.Increment(fmt.Sprintf("other-%d", i))
+	}
+	if s.Estimate("key1") >= 10 {
+		t.Errorf("Expected estimate to be aged, got %d", s.Estimate("key1"))
+	}
+}
@@ -0,0 +1,470 @@
+package goCache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goCache/goCache/consistent"
+	"goCache/goCache/registry"
+	"goCache/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	protocolVersion = "1" // 节点间协议版本, 随注册信息发布
+	membersPath     = "/members"
+	weightPath      = "/weight"
+)
+
+// cluster 维护集群成员与一致性hash, 由 HTTPPool 与 GrpcPeer 共用
+type cluster struct {
+	self           string          // 自身地址
+	node           *pb.ServiceNode // 自身的注册信息, 修改时整体替换
+	joined         bool            // 是否已注册
+	spread         bool            // 有节点带拓扑标签时副本分散到不同的 zone
+	registry       registry.Registry
+	mu             sync.RWMutex
+	consistentHash consistent.Picker   // 节点放置算法
+	bounded        *consistent.Bounded // 有界负载, 为 nil 时不启用, 否则与 consistentHash 相同
+	members        map[string]*pb.ServiceNode
+	getters        map[string]PeerGetter
+	newGetter      func(name string, addr string) (PeerGetter, error)
+	cancel         context.CancelFunc                  // 停止监听成员变更
+	rebalances     []func(moved func(key string) bool) // 成员变化后的回调, 由 group 注册
+}
+
+func newCluster(self string, option PeerOption, newGetter func(name string, addr string) (PeerGetter, error)) *cluster {
+	c := &cluster{
+		self: self,
+		node: &pb.ServiceNode{
+			Name:          self,
+			Addr:          self,
+			Weight:        option.nodeWeight(),
+			Zone:          option.zone,
+			Rack:          option.rack,
+			Host:          option.host,
+			Version:       protocolVersion,
+			CapacityBytes: option.capacity,
+			StartTime:     time.Now().UnixMilli(),
+			Labels:        option.labels,
+		},
+		registry:       option.registry,
+		consistentHash: option.picker,
+		members:        make(map[string]*pb.ServiceNode),
+		getters:        make(map[string]PeerGetter),
+		newGetter:      newGetter,
+	}
+	if c.consistentHash == nil {
+		c.consistentHash = consistent.New(0, nil)
+	}
+	if option.boundedLoad > 0 {
+		c.bounded = consistent.NewBounded(c.consistentHash, option.boundedLoad)
+		c.consistentHash = c.bounded
+	}
+	return c
+}
+
+// join 注册自身并开始监听成员变更
+func (c *cluster) join(ctx context.Context) error {
+	c.mu.Lock()
+	node := c.node
+	c.joined = true
+	c.mu.Unlock()
+	if err := c.registry.Register(ctx, node); err != nil {
+		return fmt.Errorf("failed to register, err: %w", err)
+	}
+	watchCtx, cancel := context.WithCancel(context.Background())
+	events, err := c.registry.Watch(watchCtx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to watch registry, err: %w", err)
+	}
+	c.cancel = cancel
+	go c.watch(events)
+	return nil
+}
+
+// leave 从注册中心注销自身, 其他节点不再将请求路由到本节点
+func (c *cluster) leave(ctx context.Context) error {
+	c.mu.Lock()
+	c.joined = false
+	c.mu.Unlock()
+	if err := c.registry.Deregister(ctx); err != nil {
+		return fmt.Errorf("failed to deregister, err: %w", err)
+	}
+	return nil
+}
+
+// close 停止监听成员变更, 关闭注册中心与到其他节点的连接
+func (c *cluster) close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	var errs []error
+	if err := c.registry.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close registry, err: %w", err))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, getter := range c.getters {
+		closeGetter(getter)
+		delete(c.getters, name)
+	}
+	return errors.Join(errs...)
+}
+
+// maxBatch 每次最多合并应用的成员变更事件数
+const maxBatch = 256
+
+// watch 合并已到达的事件后批量应用, 启动时注册中心推送的所有节点只需更新少数几次
+func (c *cluster) watch(events <-chan registry.Event) {
+	for ev := range events {
+		batch := []registry.Event{ev}
+	drain:
+		for len(batch) < maxBatch {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, ev)
+			default:
+				break drain
+			}
+		}
+		c.apply(batch)
+	}
+}
+
+func (c *cluster) SetService(node *pb.ServiceNode) {
+	c.apply([]registry.Event{{Type: registry.EventPut, Node: node}})
+}
+
+func (c *cluster) DelService(name string) {
+	c.apply([]registry.Event{{Type: registry.EventDelete, Node: &pb.ServiceNode{Name: name}}})
+}
+
+// apply 批量应用成员变更, 同一节点只保留最后一个事件, 一致性hash每批只增删一次
+func (c *cluster) apply(events []registry.Event) {
+	var (
+		latest = make(map[string]registry.Event, len(events))
+		order  = make([]string, 0, len(events))
+	)
+	for _, ev := range events {
+		name := ev.Node.GetName()
+		if _, ok := latest[name]; !ok {
+			order = append(order, name)
+		}
+		latest[name] = ev
+	}
+
+	c.mu.Lock()
+	var (
+		added   []consistent.Node
+		removed []string
+		old     *consistent.Consistent
+	)
+	ring, ok := c.ring()
+	if ok && len(c.rebalances) > 0 {
+		old = ring.Clone()
+	}
+	for _, name := range order {
+		switch ev := latest[name]; ev.Type {
+		case registry.EventPut:
+			log.Println("add server node, ", name)
+			added = append(added, consistent.Node{
+				Name:   name,
+				Addr:   ev.Node.GetAddr(),
+				Weight: ev.Node.GetWeight(),
+				Zone:   ev.Node.GetZone(),
+				Rack:   ev.Node.GetRack(),
+				Host:   ev.Node.GetHost(),
+			})
+			c.members[name] = ev.Node
+			c.setGetter(ev.Node)
+		case registry.EventDelete:
+			log.Println("del server node, ", name)
+			removed = append(removed, name)
+			delete(c.members, name)
+			if getter, ok := c.getters[name]; ok {
+				closeGetter(getter)
+				delete(c.getters, name)
+			}
+		}
+	}
+	// 带拓扑标签的节点全部离开后不再分散副本
+	c.spread = false
+	for _, node := range c.members {
+		if node.GetZone() != "" || node.GetRack() != "" || node.GetHost() != "" {
+			c.spread = true
+			break
+		}
+	}
+	if len(removed) > 0 {
+		c.consistentHash.DelNodes(removed...)
+	}
+	if len(added) > 0 {
+		c.consistentHash.AddNodes(added...)
+	}
+	var moves []consistent.Move
+	if old != nil {
+		moves = old.Diff(ring)
+	}
+	rebalances := c.rebalances
+	c.mu.Unlock()
+
+	if len(rebalances) == 0 || len(added)+len(removed) == 0 {
+		return
+	}
+	// 其他 picker 无法计算区间的变化, 由 group 按当前的所有者检查所有 key
+	moved := func(key string) bool { return true }
+	if old != nil {
+		if len(moves) == 0 {
+			return
+		}
+		// 本节点失去的区间
+		var lost []consistent.Move
+		for _, m := range moves {
+			if m.From.Addr == c.self {
+				lost = append(lost, m)
+			}
+		}
+		moved = func(key string) bool {
+			hash := ring.Hash(key)
+			for _, m := range lost {
+				if m.Contains(hash) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	for _, fn := range rebalances {
+		go fn(moved)
+	}
+}
+
+// SetWeight 运行时修改自身权重, 已注册时重新注册, 其他节点的一致性hash随注册中心的事件调整
+func (c *cluster) SetWeight(ctx context.Context, weight int32) error {
+	if weight <= 0 {
+		return fmt.Errorf("invalid weight: %d", weight)
+	}
+	c.mu.Lock()
+	node := proto.Clone(c.node).(*pb.ServiceNode)
+	node.Weight = weight
+	if !c.joined {
+		c.node = node
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+	// 注册中心不能发布变更时 (registry.ErrImmutable) 不修改本节点, 避免各节点的放置不一致
+	if err := c.registry.Register(ctx, node); err != nil {
+		return fmt.Errorf("failed to update weight, err: %w", err)
+	}
+	c.mu.Lock()
+	c.node = node
+	c.mu.Unlock()
+	// 本节点立即调整, 不依赖注册中心推送自身的变更
+	c.SetService(node)
+	return nil
+}
+
+// Members 当前集群成员及其元数据, 按名称排序
+func (c *cluster) Members() []*pb.ServiceNode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	members := make([]*pb.ServiceNode, 0, len(c.members))
+	for _, node := range c.members {
+		members = append(members, node)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].GetName() < members[j].GetName()
+	})
+	return members
+}
+
+// onRebalance 环发生变化后调用 fn, moved 判断 key 所在的区间是否已从本节点移走
+func (c *cluster) onRebalance(fn func(moved func(key string) bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebalances = append(c.rebalances, fn)
+}
+
+// ring 底层的虚拟节点一致性hash, 其他放置算法不支持区间比较, 调用方持有锁
+func (c *cluster) ring() (*consistent.Consistent, bool) {
+	p := c.consistentHash
+	if b, ok := p.(*consistent.Bounded); ok {
+		p = b.Picker
+	}
+	ring, ok := p.(*consistent.Consistent)
+	return ring, ok
+}
+
+// setGetter 创建节点的 PeerGetter, 节点重新注册时复用已有连接, 调用方持有锁
+func (c *cluster) setGetter(node *pb.ServiceNode) {
+	if getter, ok := c.getters[node.GetName()]; ok {
+		if getter.Addr() == node.GetAddr() {
+			return
+		}
+		closeGetter(getter)
+	}
+	getter, err := c.newGetter(node.GetName(), node.GetAddr())
+	if err != nil {
+		log.Println("failed to create peer getter, err: ", err)
+		delete(c.getters, node.GetName())
+		return
+	}
+	c.getters[node.GetName()] = getter
+}
+
+func (c *cluster) PickPeer(key string) (PeerGetter, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	node, err := c.consistentHash.GetNode(key)
+	if err != nil || node.Addr == c.self {
+		return nil, false
+	}
+	getter, ok := c.getters[node.Name]
+	if !ok {
+		return nil, false
+	}
+	return c.loadGetter(getter), true
+}
+
+func (c *cluster) PickPeers(key string, n int) []PeerGetter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	count := n
+	if c.spread && n > 1 {
+		count = math.MaxInt32
+	}
+	nodes, err := c.consistentHash.GetNodes(key, count)
+	if err != nil {
+		return nil
+	}
+	if count != n {
+		nodes = consistent.Spread(nodes, n)
+	}
+	if c.node.GetZone() != "" {
+		c.local(nodes)
+	}
+	peers := make([]PeerGetter, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Addr == c.self {
+			peers = append(peers, nil)
+			continue
+		}
+		if getter, ok := c.getters[node.Name]; ok {
+			peers = append(peers, c.loadGetter(getter))
+		}
+	}
+	return peers
+}
+
+// local 将本节点与同 zone 的节点排在前面, 读取时优先访问本 zone, 其余保持原来的优先级, 调用方持有锁
+func (c *cluster) local(nodes []consistent.Node) {
+	rank := func(node consistent.Node) int {
+		switch {
+		case node.Addr == c.self:
+			return 0
+		case node.Zone == c.node.GetZone():
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return rank(nodes[i]) < rank(nodes[j])
+	})
+}
+
+// loadGetter 有界负载时包装 getter, 统计处理中的请求, 使负载达到上限的节点被跳过
+func (c *cluster) loadGetter(getter PeerGetter) PeerGetter {
+	if c.bounded == nil {
+		return getter
+	}
+	return &loadGetter{PeerGetter: getter, loads: c.bounded}
+}
+
+// loadGetter 在请求期间增加节点的负载
+type loadGetter struct {
+	PeerGetter
+	loads *consistent.Bounded
+}
+
+func (l *loadGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	l.loads.Inc(l.Name())
+	defer l.loads.Done(l.Name())
+	return l.PeerGetter.Get(ctx, group, key)
+}
+
+func (l *loadGetter) GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	l.loads.Inc(l.Name())
+	defer l.loads.Done(l.Name())
+	return l.PeerGetter.GetMany(ctx, group, keys)
+}
+
+func closeGetter(getter PeerGetter) {
+	if closer, ok := getter.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("failed to close peer getter, err: ", err)
+		}
+	}
+}
+
+// adminHandler 管理接口: /metrics, /members 与 /weight, 应只在管理地址上提供
+func (c *cluster) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, MetricsHandler())
+	mux.HandleFunc(membersPath, c.membersHandler)
+	mux.HandleFunc(weightPath, c.weightHandler)
+	return mux
+}
+
+// membersHandler 以 JSON 数组返回当前集群成员
+func (c *cluster) membersHandler(w http.ResponseWriter, r *http.Request) {
+	members := c.Members()
+	items := make([]json.RawMessage, 0, len(members))
+	for _, node := range members {
+		data, err := protojson.Marshal(node)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, data)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// weightHandler POST ?weight=N 修改自身权重
+func (c *cluster) weightHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	weight, err := strconv.ParseInt(r.URL.Query().Get("weight"), 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid weight, err: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err = c.SetWeight(r.Context(), int32(weight)); errors.Is(err, registry.ErrImmutable) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,450 @@
+package goCache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCache/goCache/consistent"
+	"goCache/goCache/registry"
+	"goCache/pb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memRegistry 内存注册中心, 由测试直接推送事件
+type memRegistry struct {
+	self   *pb.ServiceNode
+	events chan registry.Event
+}
+
+func (m *memRegistry) Register(ctx context.Context, node *pb.ServiceNode) error {
+	m.self = node
+	return nil
+}
+
+func (m *memRegistry) Watch(ctx context.Context) (<-chan registry.Event, error) {
+	return m.events, nil
+}
+
+func (m *memRegistry) Deregister(ctx context.Context) error {
+	m.self = nil
+	return nil
+}
+
+func (m *memRegistry) Close() error {
+	close(m.events)
+	return nil
+}
+
+func TestCluster(t *testing.T) {
+	reg := &memRegistry{events: make(chan registry.Event)}
+	peer := NewHTTPPoolWithOptions("http://localhost:8001", nil, WithPeerOptionsRegistry(reg))
+	if err := peer.join(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if reg.self.GetAddr() != "http://localhost:8001" {
+		t.Fatalf("expected self to be registered, got %v", reg.self)
+	}
+
+	remote := &pb.ServiceNode{Name: "remote", Addr: "http://localhost:8002", Weight: 1}
+	reg.events <- registry.Event{Type: registry.EventPut, Node: remote}
+	// 只有 remote 节点时所有 key 都路由到 remote
+	waitFor(t, func() bool {
+		getter, ok := peer.PickPeer("key")
+		return ok && getter.Name() == "remote"
+	})
+
+	reg.events <- registry.Event{Type: registry.EventDelete, Node: &pb.ServiceNode{Name: "remote"}}
+	waitFor(t, func() bool {
+		_, ok := peer.PickPeer("key")
+		return !ok
+	})
+	peer.mu.RLock()
+	defer peer.mu.RUnlock()
+	if len(peer.getters) != 0 {
+		t.Errorf("expected getters to be removed, got %v", peer.getters)
+	}
+}
+
+// TestCluster_Gossip 由 gossip 成员驱动一致性hash
+func TestCluster_Gossip(t *testing.T) {
+	config := registry.DefaultGossipConfig()
+	config.ProbeInterval = time.Millisecond * 20
+	config.ProbeTimeout = time.Millisecond * 10
+	r1, err := registry.NewGossip("127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r1.Close()
+	r2, err := registry.NewGossip("127.0.0.1:0", config, r1.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+
+	peer1 := NewHTTPPoolWithOptions("http://localhost:8021", nil, WithPeerOptionsRegistry(r1))
+	peer2 := NewHTTPPoolWithOptions("http://localhost:8022", nil, WithPeerOptionsRegistry(r2))
+	if err = peer1.join(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err = peer2.join(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 每个节点都能将部分 key 路由到对方
+	for _, c := range []struct {
+		peer   *HTTPPool
+		remote string
+	}{{peer1, "http://localhost:8022"}, {peer2, "http://localhost:8021"}} {
+		waitFor(t, func() bool {
+			for i := 0; i < 100; i++ {
+				if getter, ok := c.peer.PickPeer(fmt.Sprintf("key%d", i)); ok && getter.Name() == c.remote {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// countingPicker 统计批量增删的次数
+type countingPicker struct {
+	*consistent.Consistent
+	adds, dels int
+}
+
+func (c *countingPicker) AddNodes(nodes ...consistent.Node) {
+	c.adds++
+	c.Consistent.AddNodes(nodes...)
+}
+
+func (c *countingPicker) DelNodes(names ...string) {
+	c.dels++
+	c.Consistent.DelNodes(names...)
+}
+
+func TestCluster_Apply(t *testing.T) {
+	picker := &countingPicker{Consistent: consistent.New(0, nil)}
+	peer := NewHTTPPoolWithOptions("http://localhost:8001", nil,
+		WithPeerOptionsRegistry(registry.NewStatic()), WithPeerOptionsPicker(picker))
+	peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+		return &fakeGetter{name: addr}, nil
+	}
+
+	var events []registry.Event
+	for i := 0; i < 200; i++ {
+		addr := fmt.Sprintf("http://localhost:%d", 9000+i)
+		events = append(events, registry.Event{Type: registry.EventPut, Node: &pb.ServiceNode{Name: addr, Addr: addr, Weight: 1}})
+	}
+	// 同一节点只保留最后一个事件
+	events = append(events,
+		registry.Event{Type: registry.EventDelete, Node: &pb.ServiceNode{Name: "http://localhost:9000"}},
+		registry.Event{Type: registry.EventPut, Node: &pb.ServiceNode{Name: "http://localhost:9001", Addr: "http://localhost:9999", Weight: 1}},
+	)
+	peer.apply(events)
+
+	if picker.adds != 1 || picker.dels != 1 {
+		t.Fatalf("expected one batched add and delete, got %d adds and %d dels", picker.adds, picker.dels)
+	}
+	if len(peer.getters) != 199 {
+		t.Fatalf("expected 199 getters, got %d", len(peer.getters))
+	}
+	if _, ok := peer.getters["http://localhost:9000"]; ok {
+		t.Fatal("expected deleted node to be removed")
+	}
+	if getter := peer.getters["http://localhost:9001"]; getter.Name() != "http://localhost:9999" {
+		t.Fatalf("expected getter of latest address, got %s", getter.Name())
+	}
+	for _, node := range peer.PickPeers("key", 199) {
+		if node.Name() == "http://localhost:9000" {
+			t.Fatal("expected deleted node not to be picked")
+		}
+	}
+}
+
+func TestCluster_Topology(t *testing.T) {
+	self := "http://localhost:8051"
+	peer := NewHTTPPoolWithOptions(self, nil,
+		WithPeerOptionsRegistry(registry.NewStatic()), WithPeerOptionsTopology("az1", "", ""))
+	peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+		return &fakeGetter{name: addr}, nil
+	}
+	zones := make(map[string]string)
+	for i, zone := range []string{"az1", "az1", "az1", "az2", "az2", "az2", "az3", "az3", "az3"} {
+		addr := fmt.Sprintf("http://localhost:%d", 8051+i)
+		zones[addr] = zone
+		peer.SetService(&pb.ServiceNode{Name: addr, Addr: addr, Weight: 1, Zone: zone})
+	}
+	zoneOf := func(getter PeerGetter) string {
+		if getter == nil {
+			return zones[self]
+		}
+		return zones[getter.Name()]
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners := peer.PickPeers(key, 3)
+		if len(owners) != 3 {
+			t.Fatalf("expected 3 owners, got %d", len(owners))
+		}
+		// 副本分散到三个 zone
+		seen := make(map[string]bool)
+		for _, owner := range owners {
+			seen[zoneOf(owner)] = true
+		}
+		if len(seen) != 3 {
+			t.Fatalf("expected owners of %s in 3 zones, got %v", key, seen)
+		}
+		// 本 zone 的副本排在最前
+		if zoneOf(owners[0]) != "az1" {
+			t.Fatalf("expected local zone replica first for %s, got %s", key, zoneOf(owners[0]))
+		}
+	}
+
+	// 带拓扑标签的节点离开后按一致性hash的顺序选择副本
+	for addr := range zones {
+		if addr != self {
+			peer.DelService(addr)
+		}
+	}
+	peer.SetService(&pb.ServiceNode{Name: self, Addr: self, Weight: 1})
+	peer.SetService(&pb.ServiceNode{Name: "http://localhost:8060", Addr: "http://localhost:8060", Weight: 1})
+	if peer.spread {
+		t.Fatal("expected zone spread to be disabled without labeled nodes")
+	}
+}
+
+func TestCluster_Weight(t *testing.T) {
+	self := "http://localhost:8061"
+	reg := &memRegistry{events: make(chan registry.Event, 2)}
+	peer := NewHTTPPoolWithOptions(self, nil, WithPeerOptionsRegistry(reg),
+		WithPeerOptionsCapacity(256<<20), WithPeerOptionsLabels(map[string]string{"role": "cache"}))
+	peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+		return &fakeGetter{name: addr}, nil
+	}
+	if err := peer.join(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 按容量计算权重, 并发布元数据
+	if reg.self.GetWeight() != 4 || reg.self.GetCapacityBytes() != 256<<20 || reg.self.GetVersion() != protocolVersion ||
+		reg.self.GetStartTime() == 0 || reg.self.GetLabels()["role"] != "cache" {
+		t.Fatalf("unexpected registered node %v", reg.self)
+	}
+
+	remote := &pb.ServiceNode{Name: "http://localhost:8062", Addr: "http://localhost:8062", Weight: 4}
+	reg.events <- registry.Event{Type: registry.EventPut, Node: reg.self}
+	reg.events <- registry.Event{Type: registry.EventPut, Node: remote}
+	waitFor(t, func() bool {
+		return len(peer.Members()) == 2
+	})
+	remoteKeys := func() int {
+		n := 0
+		for i := 0; i < 1000; i++ {
+			if _, ok := peer.PickPeer(fmt.Sprintf("key%d", i)); ok {
+				n++
+			}
+		}
+		return n
+	}
+	before := remoteKeys()
+
+	// 数据端口不提供 /weight
+	rec := httptest.NewRecorder()
+	peer.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/weight?weight=12", nil))
+	if reg.self.GetWeight() != 4 {
+		t.Fatalf("expected weight not to change through the data port, got %v", reg.self)
+	}
+
+	// 运行时通过管理接口修改权重, 重新注册并立即调整一致性hash
+	rec = httptest.NewRecorder()
+	peer.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/weight?weight=12", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected weight update to succeed, got %d %s", rec.Code, rec.Body.String())
+	}
+	if reg.self.GetWeight() != 12 || reg.self.GetLabels()["role"] != "cache" {
+		t.Fatalf("expected node to be registered with new weight, got %v", reg.self)
+	}
+	if after := remoteKeys(); after >= before {
+		t.Fatalf("expected fewer remote keys after increasing weight, got %d before and %d after", before, after)
+	}
+	if err := peer.SetWeight(context.Background(), 0); err == nil {
+		t.Fatal("expected error for invalid weight")
+	}
+
+	rec = httptest.NewRecorder()
+	peer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/members", nil))
+	if !strings.Contains(rec.Body.String(), `"weight":12`) || !strings.Contains(rec.Body.String(), remote.GetAddr()) {
+		t.Fatalf("unexpected members %s", rec.Body.String())
+	}
+}
+
+func TestCluster_WeightImmutable(t *testing.T) {
+	self := "http://localhost:8063"
+	peer := NewHTTPPoolWithOptions(self, nil, WithPeerOptionsRegistry(registry.NewStatic()))
+	if err := peer.join(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer peer.close()
+	waitFor(t, func() bool {
+		return len(peer.Members()) == 1
+	})
+
+	// 静态成员不能发布权重变更, 本节点保持原来的权重
+	if err := peer.SetWeight(context.Background(), 5); !errors.Is(err, registry.ErrImmutable) {
+		t.Fatalf("expected ErrImmutable, got %v", err)
+	}
+	if w := peer.Members()[0].GetWeight(); w != 1 {
+		t.Fatalf("expected weight 1, got %d", w)
+	}
+	rec := httptest.NewRecorder()
+	peer.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/weight?weight=5", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", rec.Code)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingGetter 请求阻塞直到 release 关闭
+type blockingGetter struct {
+	fakeGetter
+	release chan struct{}
+}
+
+func (b *blockingGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	<-b.release
+	return []byte(key), nil
+}
+
+func TestCluster_BoundedLoad(t *testing.T) {
+	release := make(chan struct{})
+	peer := NewHTTPPoolWithOptions("http://localhost:8031", nil,
+		WithPeerOptionsRegistry(registry.NewStatic()), WithPeerOptionsBoundedLoad(0.25))
+	peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+		return &blockingGetter{fakeGetter: fakeGetter{name: name}, release: release}, nil
+	}
+	for i := 1; i <= 3; i++ {
+		addr := fmt.Sprintf("http://localhost:803%d", i+1)
+		peer.SetService(&pb.ServiceNode{Name: addr, Addr: addr, Weight: 1})
+	}
+
+	// 找到属于远端节点的 key
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("hot%d", i)
+		if _, ok := peer.PickPeer(key); ok {
+			break
+		}
+	}
+	owner, _ := peer.PickPeer(key)
+
+	var wg sync.WaitGroup
+	picked := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		getter, ok := peer.PickPeer(key)
+		if !ok {
+			picked["self"]++
+			continue
+		}
+		picked[getter.Name()]++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			getter.Get(context.Background(), "group", key)
+		}()
+		// 等待请求开始, 计入负载
+		waitFor(t, func() bool {
+			return peer.bounded.Load(getter.Name()) == int64(picked[getter.Name()])
+		})
+	}
+	close(release)
+	wg.Wait()
+
+	if len(picked) < 2 {
+		t.Fatalf("expected hot key to spill over, got %v", picked)
+	}
+	if picked[owner.Name()] >= 30 {
+		t.Fatalf("expected owner %s to be bounded, got %v", owner.Name(), picked)
+	}
+	if load := peer.bounded.Load(owner.Name()); load != 0 {
+		t.Fatalf("expected load to be released, got %d", load)
+	}
+}
+
+func TestGroup_BoundedLoad(t *testing.T) {
+	release := make(chan struct{})
+	peer := NewHTTPPoolWithOptions("http://localhost:8081", nil,
+		WithPeerOptionsRegistry(registry.NewStatic()), WithPeerOptionsBoundedLoad(0.25))
+	peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+		return &blockingGetter{fakeGetter: fakeGetter{name: name}, release: release}, nil
+	}
+	var names []string
+	for i := 2; i <= 4; i++ {
+		addr := fmt.Sprintf("http://localhost:808%d", i)
+		names = append(names, addr)
+		peer.SetService(&pb.ServiceNode{Name: addr, Addr: addr, Weight: 1})
+	}
+	group := NewGroup("bounded", GetterFunc(func(key string) ([]byte, error) {
+		t.Errorf("key %s should be loaded by a peer", key)
+		return nil, nil
+	}))
+	group.RegisterPeer(peer)
+
+	// 热点范围: 不考虑负载时属于同一个节点的 key
+	owner := names[0]
+	var keys []string
+	for i := 0; len(keys) < 30; i++ {
+		key := fmt.Sprintf("hot%d", i)
+		if node, _ := peer.bounded.Picker.GetNode(key); node.Name == owner {
+			keys = append(keys, key)
+		}
+	}
+	total := func() (sum int64) {
+		for _, name := range names {
+			sum += peer.bounded.Load(name)
+		}
+		return sum
+	}
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if v, err := group.Get(key); err != nil || v.String() != key {
+				t.Errorf("expected %s, got %v %v", key, v, err)
+			}
+		}(key)
+		// 等待请求开始, 计入负载
+		waitFor(t, func() bool {
+			return total() == int64(i+1)
+		})
+	}
+	// ceil(1.25 * 30 / 3) = 13
+	if load := peer.bounded.Load(owner); load > 13 {
+		t.Fatalf("expected owner load <= 13, got %d", load)
+	}
+	for _, name := range names[1:] {
+		if peer.bounded.Load(name) == 0 {
+			t.Fatalf("expected %s to take load", name)
+		}
+	}
+	close(release)
+	wg.Wait()
+	if load := total(); load != 0 {
+		t.Fatalf("expected load to be released, got %d", load)
+	}
+}
@@ -0,0 +1,142 @@
+package consistent
+
+import (
+	"math"
+	"sync"
+)
+
+const defaultEpsilon = 0.25
+
+// Bounded 有界负载的一致性hash (Consistent Hashing with Bounded Loads)
+// 每个节点的负载不超过 (1+epsilon) 倍的平均负载, 超过时按 Picker 的优先级选择下一个节点,
+// 从而将热点 key 范围的请求分散到其他节点上
+type Bounded struct {
+	Picker
+	epsilon float64
+	mu      sync.Mutex
+	members map[string]bool  // 当前节点
+	loads   map[string]int64 // 节点名称 -> 负载
+}
+
+// NewBounded epsilon 不大于 0 时为 0.25, 节点须通过 Bounded 添加与删除
+func NewBounded(p Picker, epsilon float64) *Bounded {
+	if epsilon <= 0 {
+		epsilon = defaultEpsilon
+	}
+	return &Bounded{
+		Picker:  p,
+		epsilon: epsilon,
+		members: make(map[string]bool),
+		loads:   make(map[string]int64),
+	}
+}
+
+func (b *Bounded) AddNodes(nodes ...Node) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, node := range nodes {
+		b.members[node.Name] = true
+	}
+	b.Picker.AddNodes(nodes...)
+}
+
+func (b *Bounded) AddNode(node Node) {
+	b.AddNodes(node)
+}
+
+func (b *Bounded) DelNodes(names ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, name := range names {
+		delete(b.members, name)
+	}
+	b.Picker.DelNodes(names...)
+}
+
+func (b *Bounded) DelNode(name string) {
+	b.DelNodes(name)
+}
+
+// GetNode 按优先级选择第一个负载未达到上限的节点
+func (b *Bounded) GetNode(key string) (Node, error) {
+	nodes, err := b.GetNodes(key, 1)
+	if err != nil {
+		return Node{}, err
+	}
+	return nodes[0], nil
+}
+
+// GetNodes 按优先级返回 n 个节点, 负载未达到上限的节点排在前面, 第一个与 GetNode 相同
+// 查找范围从 n 个节点开始逐步加倍, 找到 n 个负载未达到上限的节点即停止, 只有节点普遍过载时才遍历所有节点
+func (b *Bounded) GetNodes(key string, n int) ([]Node, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n <= 0 {
+		return nil, nil
+	}
+	capacity := b.capacity()
+	for k := n; ; k *= 2 {
+		if k > len(b.members) {
+			k = len(b.members)
+		}
+		nodes, err := b.Picker.GetNodes(key, k)
+		if err != nil {
+			return nil, err
+		}
+		sorted := make([]Node, 0, n)
+		for _, node := range nodes {
+			if b.loads[node.Name]+1 <= capacity {
+				if sorted = append(sorted, node); len(sorted) == n {
+					return sorted, nil
+				}
+			}
+		}
+		if len(nodes) < k || k >= len(b.members) {
+			// 已查找所有节点, 负载达到上限的节点按优先级补充
+			for _, node := range nodes {
+				if len(sorted) == n {
+					break
+				}
+				if b.loads[node.Name]+1 > capacity {
+					sorted = append(sorted, node)
+				}
+			}
+			return sorted, nil
+		}
+	}
+}
+
+// Inc 节点负载加一, 例如请求开始时
+func (b *Bounded) Inc(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.loads[name]++
+}
+
+// Done 节点负载减一, 例如请求结束时
+func (b *Bounded) Done(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.loads[name]--; b.loads[name] <= 0 {
+		delete(b.loads, name)
+	}
+}
+
+// Load 节点当前负载
+func (b *Bounded) Load(name string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.loads[name]
+}
+
+// capacity 每个节点的负载上限 ceil((1+epsilon) * (total+1) / n), 调用方持有锁
+func (b *Bounded) capacity() int64 {
+	if len(b.members) == 0 {
+		return 0
+	}
+	var total int64
+	for name := range b.members {
+		total += b.loads[name]
+	}
+	return int64(math.Ceil((1 + b.epsilon) * float64(total+1) / float64(len(b.members))))
+}
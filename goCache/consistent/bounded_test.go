@@ -0,0 +1,108 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBounded(t *testing.T) {
+	b := NewBounded(New(50, nil), 0.25)
+	b.AddNodes(
+		Node{Name: "node1", Addr: "localhost:8001", Weight: 1},
+		Node{Name: "node2", Addr: "localhost:8002", Weight: 1},
+		Node{Name: "node3", Addr: "localhost:8003", Weight: 1},
+		Node{Name: "node4", Addr: "localhost:8004", Weight: 1},
+	)
+
+	// 没有负载时与普通一致性hash相同
+	owner, _ := b.Picker.GetNode("hot")
+	if node, _ := b.GetNode("hot"); node.Name != owner.Name {
+		t.Fatalf("expected %s without load, got %s", owner.Name, node.Name)
+	}
+
+	// 同一个热点 key 的请求分散到多个节点, 且每个节点不超过上限
+	for i := 0; i < 100; i++ {
+		node, err := b.GetNode("hot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Inc(node.Name)
+	}
+	var max int64
+	for i := 1; i <= 4; i++ {
+		load := b.Load(fmt.Sprintf("node%d", i))
+		if load == 0 {
+			t.Errorf("expected node%d to take load", i)
+		}
+		if load > max {
+			max = load
+		}
+	}
+	// ceil(1.25 * 100 / 4) = 32
+	if max > 32 {
+		t.Fatalf("expected max load <= 32, got %d", max)
+	}
+
+	// GetNodes 的第一个节点与 GetNode 相同, 其余为按优先级排列的其他节点
+	node, _ := b.GetNode("hot")
+	nodes, _ := b.GetNodes("hot", 4)
+	if len(nodes) != 4 || nodes[0].Name != node.Name {
+		t.Fatalf("expected 4 nodes starting with %s, got %v", node.Name, nodes)
+	}
+
+	for b.Load(owner.Name) > 0 {
+		b.Done(owner.Name)
+	}
+	if node, _ := b.GetNode("hot"); node.Name != owner.Name {
+		t.Fatalf("expected %s after release, got %s", owner.Name, node.Name)
+	}
+}
+
+// countingPicker 记录每次查找的节点个数
+type countingPicker struct {
+	Picker
+	requested []int
+}
+
+func (c *countingPicker) GetNodes(key string, n int) ([]Node, error) {
+	c.requested = append(c.requested, n)
+	return c.Picker.GetNodes(key, n)
+}
+
+func TestBounded_GetNodesLazy(t *testing.T) {
+	picker := &countingPicker{Picker: New(50, nil)}
+	b := NewBounded(picker, 0.25)
+	for i := 0; i < 64; i++ {
+		b.AddNode(Node{Name: fmt.Sprintf("node%d", i), Addr: fmt.Sprintf("localhost:%d", 8000+i), Weight: 1})
+	}
+	all, _ := picker.Picker.GetNodes("hot", 64)
+
+	// 没有节点过载时只查找需要的节点个数
+	if nodes, _ := b.GetNodes("hot", 2); len(nodes) != 2 || nodes[0] != all[0] || nodes[1] != all[1] {
+		t.Fatalf("expected %v, got %v", all[:2], nodes)
+	}
+	if len(picker.requested) != 1 || picker.requested[0] != 2 {
+		t.Fatalf("expected a single lookup of 2 nodes, got %v", picker.requested)
+	}
+
+	// 前面的节点过载时逐步扩大查找范围, 结果与遍历所有节点相同
+	for _, node := range all[:3] {
+		for i := 0; i < 10; i++ {
+			b.Inc(node.Name)
+		}
+	}
+	picker.requested = nil
+	nodes, _ := b.GetNodes("hot", 2)
+	if len(nodes) != 2 || nodes[0] != all[3] || nodes[1] != all[4] {
+		t.Fatalf("expected %v, got %v", all[3:5], nodes)
+	}
+	if fmt.Sprint(picker.requested) != "[2 4 8]" {
+		t.Fatalf("expected lookups of [2 4 8] nodes, got %v", picker.requested)
+	}
+
+	// 负载未达到上限的节点不足时, 过载的节点按优先级排在最后
+	nodes, _ = b.GetNodes("hot", 64)
+	if want := fmt.Sprint(append(append([]Node{}, all[3:]...), all[:3]...)); fmt.Sprint(nodes) != want {
+		t.Fatalf("expected overloaded nodes last, got %v", nodes)
+	}
+}
@@ -3,13 +3,13 @@ package consistent
 import (
 	"fmt"
 	"hash/crc32"
-	"math"
 	"sort"
 	"sync"
 )
 
 const (
 	defaultReplicas = 50
+	smallNodes      = 8 // GetNodes 查找不超过该个数的节点时不使用 map 查重
 )
 
 type HashFunc func(data []byte) uint32
@@ -19,11 +19,14 @@ type Node struct {
 	Name   string // Name 节点名称
 	Addr   string // Addr 节点地址
 	Weight int32  // Weight 节点权重
+	Zone   string // Zone 可用区
+	Rack   string // Rack 机架
+	Host   string // Host 主机
 }
 
 type Consistent struct {
 	hash     HashFunc        // hash 计算函数
-	replicas int             // 虚拟节点个数
+	replicas int             // 每单位权重的虚拟节点个数
 	ring     []Node          // hash 环
 	mp       map[string]Node // 真实node记录
 	mu       sync.RWMutex    // 读写锁控制ring
@@ -44,61 +47,109 @@ func New(replicas int, fn HashFunc) *Consistent {
 	return c
 }
 
-func (c *Consistent) adjust() {
-	var totalWeight int32
-	for _, v := range c.mp {
-		totalWeight += v.Weight
+// points 节点的虚拟节点, 个数为 replicas * weight
+func (c *Consistent) points(node Node) []Node {
+	spots := c.replicas * int(node.Weight)
+	points := make([]Node, 0, spots)
+	for i := 1; i <= spots; i++ {
+		n := node
+		n.val = int(c.hash([]byte(fmt.Sprintf("%s:%d", node.Name, i))))
+		points = append(points, n)
 	}
-	totalVirtualSpots := c.replicas * len(c.mp)
+	return points
+}
 
-	c.ring = make([]Node, 0, totalVirtualSpots)
+// less hash 值相同时按名称排序, 保证环的顺序与加入顺序无关
+func less(a, b Node) bool {
+	if a.val != b.val {
+		return a.val < b.val
+	}
+	return a.Name < b.Name
+}
 
-	for k, node := range c.mp {
-		spots := int(math.Floor(float64(node.Weight) / float64(totalWeight) * float64(totalVirtualSpots)))
-		for i := 1; i <= spots; i++ {
-			hashVal := c.hash([]byte(fmt.Sprintf("%s:%d", k, i)))
-			n := Node{
-				Name:   k,
-				val:    int(hashVal),
-				Addr:   node.Addr,
-				Weight: node.Weight,
-			}
-			c.ring = append(c.ring, n)
+// remove 从环上删除节点的虚拟节点, 调用方持有锁
+func (c *Consistent) remove(names map[string]bool) {
+	if len(names) == 0 {
+		return
+	}
+	ring := c.ring[:0]
+	for _, node := range c.ring {
+		if !names[node.Name] {
+			ring = append(ring, node)
 		}
 	}
+	clear(c.ring[len(ring):])
+	c.ring = ring
+}
 
-	sort.Slice(c.ring, func(i, j int) bool {
-		return c.ring[i].val < c.ring[j].val
-	})
+// insert 将有序的虚拟节点从后向前原地归并到环上, 调用方持有锁
+func (c *Consistent) insert(points []Node) {
+	i := len(c.ring) - 1
+	c.ring = append(c.ring, points...)
+	for j, k := len(points)-1, len(c.ring)-1; j >= 0; k-- {
+		if i >= 0 && less(points[j], c.ring[i]) {
+			c.ring[k] = c.ring[i]
+			i--
+		} else {
+			c.ring[k] = points[j]
+			j--
+		}
+	}
 }
 
+// AddNodes 批量添加或更新节点, 只插入新增或变化节点的虚拟节点
 func (c *Consistent) AddNodes(nodes ...Node) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	var (
+		changed = make(map[string]Node, len(nodes)) // 新增或变化的节点
+		removed = make(map[string]bool)             // 变化的节点, 需删除旧的虚拟节点
+	)
 	for _, node := range nodes {
-		if node.Weight == 0 {
+		if node.Weight <= 0 {
 			node.Weight = 1
 		}
+		old, ok := c.mp[node.Name]
+		if ok && old == node {
+			continue
+		}
+		if ok {
+			removed[node.Name] = true
+		}
 		c.mp[node.Name] = node
+		changed[node.Name] = node
+	}
+	var points []Node
+	for _, node := range changed {
+		points = append(points, c.points(node)...)
 	}
-	c.adjust()
+	sort.Slice(points, func(i, j int) bool {
+		return less(points[i], points[j])
+	})
+	c.remove(removed)
+	c.insert(points)
 }
 
 func (c *Consistent) AddNode(node Node) {
+	c.AddNodes(node)
+}
+
+// DelNodes 批量删除节点
+func (c *Consistent) DelNodes(names ...string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if node.Weight == 0 {
-		node.Weight = 1
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := c.mp[name]; ok {
+			removed[name] = true
+			delete(c.mp, name)
+		}
 	}
-	c.mp[node.Name] = node
-	c.adjust()
+	c.remove(removed)
 }
 
 func (c *Consistent) DelNode(name string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	delete(c.mp, name)
-	c.adjust()
+	c.DelNodes(name)
 }
 
 func (c *Consistent) GetNode(key string) (Node, error) {
@@ -121,3 +172,57 @@ func (c *Consistent) GetNode(key string) (Node, error) {
 
 	return c.ring[idx%len(c.ring)], nil
 }
+
+// GetNodes 从 key 的位置顺时针查找 n 个不同的节点, 第一个节点与 GetNode 相同
+// 节点数不足 n 时返回所有节点
+func (c *Consistent) GetNodes(key string, n int) ([]Node, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key cannot be nil")
+	}
+
+	if len(c.ring) == 0 {
+		return nil, fmt.Errorf("ring is nil")
+	}
+
+	hashVal := int(c.hash([]byte(key)))
+
+	idx := sort.Search(len(c.ring), func(mid int) bool {
+		return c.ring[mid].val >= hashVal
+	})
+
+	if n > len(c.mp) {
+		n = len(c.mp)
+	}
+	nodes := make([]Node, 0, n)
+	// 节点较少时直接在结果中查重, 避免每次查找分配 map
+	var seen map[string]bool
+	if n > smallNodes {
+		seen = make(map[string]bool, n)
+	}
+	for i := 0; i < len(c.ring) && len(nodes) < n; i++ {
+		node := c.ring[(idx+i)%len(c.ring)]
+		if seen != nil {
+			if seen[node.Name] {
+				continue
+			}
+			seen[node.Name] = true
+		} else if containsNode(nodes, node.Name) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// containsNode nodes 中是否已有名称为 name 的节点
+func containsNode(nodes []Node, name string) bool {
+	for _, node := range nodes {
+		if node.Name == name {
+			return true
+		}
+	}
+	return false
+}
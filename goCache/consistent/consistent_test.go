@@ -1,6 +1,9 @@
 package consistent
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -41,3 +44,116 @@ func TestConsistent_GetNode(t *testing.T) {
 		t.Fatalf("ring is nil, but get node success, node: %v", node)
 	}
 }
+
+func TestConsistent_GetNodes(t *testing.T) {
+	consistent := New(50, nil)
+	consistent.AddNodes(
+		Node{Name: "node1", Addr: "localhost:8001", Weight: 1},
+		Node{Name: "node2", Addr: "localhost:8002", Weight: 1},
+		Node{Name: "node3", Addr: "localhost:8003", Weight: 1},
+	)
+
+	for _, key := range []string{"user123", "product456", "order789"} {
+		primary, _ := consistent.GetNode(key)
+		nodes, err := consistent.GetNodes(key, 2)
+		if err != nil {
+			t.Fatalf("GetNodes failed: %v", err)
+		}
+		if len(nodes) != 2 || nodes[0].Name != primary.Name || nodes[0].Name == nodes[1].Name {
+			t.Fatalf("expected 2 distinct nodes starting with %s, got %v", primary.Name, nodes)
+		}
+		// 节点数不足时返回所有节点
+		if nodes, _ = consistent.GetNodes(key, 5); len(nodes) != 3 {
+			t.Fatalf("expected all 3 nodes, got %v", nodes)
+		}
+		// 移除主节点后, 原来的第一个副本成为主节点
+		consistent.DelNode(primary.Name)
+		if node, _ := consistent.GetNode(key); node.Name != nodes[1].Name {
+			t.Fatalf("expected replica %s to take over, got %s", nodes[1].Name, node.Name)
+		}
+		consistent.AddNode(primary)
+	}
+}
+
+func TestConsistent_Incremental(t *testing.T) {
+	nodes := newNodes(20)
+	one := New(50, nil)
+	for _, node := range nodes {
+		one.AddNode(node)
+	}
+	batch := New(50, nil)
+	batch.AddNodes(nodes...)
+	// 逐个添加与批量添加得到相同的环
+	if !reflect.DeepEqual(one.ring, batch.ring) {
+		t.Fatal("expected incremental and batch rings to be equal")
+	}
+	if !sort.SliceIsSorted(one.ring, func(i, j int) bool { return less(one.ring[i], one.ring[j]) }) {
+		t.Fatal("expected ring to be sorted")
+	}
+
+	// 重复添加相同的节点不改变环
+	one.AddNode(nodes[0])
+	if len(one.ring) != 50*20 {
+		t.Fatalf("expected %d points, got %d", 50*20, len(one.ring))
+	}
+
+	// 权重变化时虚拟节点个数为 replicas * weight
+	nodes[0].Weight = 3
+	one.AddNode(nodes[0])
+	if len(one.ring) != 50*22 {
+		t.Fatalf("expected %d points, got %d", 50*22, len(one.ring))
+	}
+	updated := 0
+	for _, node := range one.ring {
+		if node.Name == nodes[0].Name && node.Weight == 3 {
+			updated++
+		}
+	}
+	if updated != 150 {
+		t.Fatalf("expected 150 points of updated node, got %d", updated)
+	}
+
+	// 批量删除后与重新构建的环相同
+	one.DelNodes(nodes[0].Name, nodes[5].Name, "unknown")
+	rebuilt := New(50, nil)
+	for i, node := range nodes {
+		if i != 0 && i != 5 {
+			rebuilt.AddNode(node)
+		}
+	}
+	if !reflect.DeepEqual(one.ring, rebuilt.ring) {
+		t.Fatal("expected ring after delete to equal rebuilt ring")
+	}
+}
+
+// BenchmarkConsistent_Join 大集群启动时逐个或批量加入所有节点
+func BenchmarkConsistent_Join(b *testing.B) {
+	for _, n := range []int{200, 1000} {
+		nodes := newNodes(n)
+		b.Run(fmt.Sprintf("one/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c := New(0, nil)
+				for _, node := range nodes {
+					c.AddNode(node)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("batch/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				New(0, nil).AddNodes(nodes...)
+			}
+		})
+	}
+}
+
+// BenchmarkConsistent_Update 大集群中一个节点加入与离开
+func BenchmarkConsistent_Update(b *testing.B) {
+	c := New(0, nil)
+	c.AddNodes(newNodes(1000)...)
+	node := Node{Name: "node-extra", Addr: "localhost:9999", Weight: 1}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.AddNode(node)
+		c.DelNode(node.Name)
+	}
+}
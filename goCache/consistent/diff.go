@@ -0,0 +1,115 @@
+package consistent
+
+import (
+	"maps"
+	"slices"
+)
+
+// Move hash 环上区间 (Start, End] 的所有者由 From 变为 To
+// Start >= End 时区间跨越 0, Start == End 表示整个环, 环为空时对应的 Node 为零值
+type Move struct {
+	Start uint32
+	End   uint32
+	From  Node
+	To    Node
+}
+
+// Contains 判断 hash 值是否在区间内
+func (m Move) Contains(hash uint32) bool {
+	if m.Start < m.End {
+		return hash > m.Start && hash <= m.End
+	}
+	return hash > m.Start || hash <= m.End
+}
+
+// Hash key 在环上的位置, 与 Move 配合判断 key 是否被移动
+func (c *Consistent) Hash(key string) uint32 {
+	return c.hash([]byte(key))
+}
+
+// Clone 复制当前的环
+func (c *Consistent) Clone() *Consistent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return &Consistent{
+		hash:     c.hash,
+		replicas: c.replicas,
+		ring:     slices.Clone(c.ring),
+		mp:       maps.Clone(c.mp),
+	}
+}
+
+// Plan 计算删除 names 并添加 nodes 后所有者发生变化的区间, 不修改 c
+func (c *Consistent) Plan(nodes []Node, names []string) []Move {
+	next := c.Clone()
+	next.DelNodes(names...)
+	next.AddNodes(nodes...)
+	return c.Diff(next)
+}
+
+// Diff 比较 c 与 next, 返回所有者发生变化的区间, 相邻且变化相同的区间会合并
+// 两者须使用相同的 hash 函数
+func (c *Consistent) Diff(next *Consistent) []Move {
+	if c == next {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	next.mu.RLock()
+	defer next.mu.RUnlock()
+
+	// 两个环上所有虚拟节点的位置, 相邻位置之间的区间在两个环上的所有者都不变
+	bounds := make([]uint32, 0, len(c.ring)+len(next.ring))
+	for _, node := range c.ring {
+		bounds = append(bounds, uint32(node.val))
+	}
+	for _, node := range next.ring {
+		bounds = append(bounds, uint32(node.val))
+	}
+	if len(bounds) == 0 {
+		return nil
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	var (
+		moves []Move
+		prev  = bounds[len(bounds)-1]
+		i, j  int // 两个环上第一个不小于当前位置的虚拟节点
+	)
+	for _, bound := range bounds {
+		for i < len(c.ring) && uint32(c.ring[i].val) < bound {
+			i++
+		}
+		for j < len(next.ring) && uint32(next.ring[j].val) < bound {
+			j++
+		}
+		from, to := owner(c.ring, i), owner(next.ring, j)
+		if from.Name != to.Name {
+			if n := len(moves); n > 0 && moves[n-1].End == prev && same(moves[n-1], from, to) {
+				moves[n-1].End = bound
+			} else {
+				moves = append(moves, Move{Start: prev, End: bound, From: from, To: to})
+			}
+		}
+		prev = bound
+	}
+	// 跨越 0 的首尾区间合并
+	if n := len(moves); n > 1 && moves[0].Start == moves[n-1].End && same(moves[0], moves[n-1].From, moves[n-1].To) {
+		moves[0].Start = moves[n-1].Start
+		moves = moves[:n-1]
+	}
+	return moves
+}
+
+// owner 位置 idx 对应的节点, idx 等于环的长度时回到第一个节点
+func owner(ring []Node, idx int) Node {
+	if len(ring) == 0 {
+		return Node{}
+	}
+	return ring[idx%len(ring)]
+}
+
+func same(m Move, from, to Node) bool {
+	return m.From.Name == from.Name && m.To.Name == to.Name
+}
@@ -0,0 +1,73 @@
+package consistent
+
+import (
+	"strconv"
+	"testing"
+)
+
+// checkMoves 对每个 key, 所有者变化当且仅当它位于某个移动的区间内, 且区间记录了新旧所有者
+func checkMoves(t *testing.T, old, next *Consistent, moves []Move) {
+	t.Helper()
+	for i := 0; i < 10000; i++ {
+		key := "key" + strconv.Itoa(i)
+		from, _ := old.GetNode(key)
+		to, _ := next.GetNode(key)
+		var found *Move
+		for _, m := range moves {
+			if m.Contains(old.Hash(key)) {
+				found = &m
+				break
+			}
+		}
+		if from.Name == to.Name {
+			if found != nil {
+				t.Fatalf("key %s not moved but in range %+v", key, *found)
+			}
+			continue
+		}
+		if found == nil || found.From.Name != from.Name || found.To.Name != to.Name {
+			t.Fatalf("key %s moved from %s to %s, got range %v", key, from.Name, to.Name, found)
+		}
+	}
+}
+
+func TestConsistent_Diff(t *testing.T) {
+	c := New(50, nil)
+	c.AddNodes(newNodes(5)...)
+
+	// 添加节点时只有区间移动到新节点
+	add := Node{Name: "node5", Addr: "localhost:8005", Weight: 1}
+	moves := c.Plan([]Node{add}, nil)
+	if len(moves) == 0 {
+		t.Fatal("expected ranges to move to new node")
+	}
+	for _, m := range moves {
+		if m.To.Name != "node5" {
+			t.Fatalf("expected ranges to move to node5, got %+v", m)
+		}
+	}
+	next := c.Clone()
+	next.AddNode(add)
+	checkMoves(t, c, next, moves)
+
+	// Plan 不修改原来的环
+	if node, _ := c.GetNodes("key", 10); len(node) != 5 {
+		t.Fatalf("expected plan not to modify ring, got %d nodes", len(node))
+	}
+
+	// 删除节点与修改权重
+	moves = c.Plan([]Node{{Name: "node1", Addr: "localhost:8001", Weight: 3}}, []string{"node2"})
+	next = c.Clone()
+	next.DelNode("node2")
+	next.AddNode(Node{Name: "node1", Addr: "localhost:8001", Weight: 3})
+	checkMoves(t, c, next, moves)
+
+	// 从空环开始时整个环移动到唯一的节点
+	moves = New(50, nil).Plan(newNodes(1), nil)
+	if len(moves) != 1 || moves[0].Start != moves[0].End || moves[0].From.Name != "" || moves[0].To.Name != "node0" {
+		t.Fatalf("expected the whole ring to move to node0, got %+v", moves)
+	}
+	if moves = c.Diff(c.Clone()); len(moves) != 0 {
+		t.Fatalf("expected no moves between equal rings, got %+v", moves)
+	}
+}
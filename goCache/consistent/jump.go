@@ -0,0 +1,116 @@
+package consistent
+
+import (
+	"sort"
+	"sync"
+)
+
+// Jump 跳跃一致性hash (Lamping & Veach), 每个节点按权重占用若干个桶, 查找为 O(log n) 且不占用额外内存
+// 桶按节点名称排序重建, 使映射只取决于节点集合而与增删顺序无关, 代价是增删节点时移动的 key 较多, 适合成员稳定的集群
+type Jump struct {
+	mu      sync.RWMutex
+	nodes   map[string]Node
+	buckets []string // 桶 -> 节点名称
+}
+
+func NewJump() *Jump {
+	return &Jump{
+		nodes: make(map[string]Node),
+	}
+}
+
+// AddNodes 批量添加节点, 只重建一次桶
+func (j *Jump) AddNodes(nodes ...Node) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, node := range nodes {
+		if node.Weight <= 0 {
+			node.Weight = 1
+		}
+		j.nodes[node.Name] = node
+	}
+	j.rebuild()
+}
+
+func (j *Jump) AddNode(node Node) {
+	j.AddNodes(node)
+}
+
+// DelNodes 批量删除节点, 只重建一次桶
+func (j *Jump) DelNodes(names ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, name := range names {
+		delete(j.nodes, name)
+	}
+	j.rebuild()
+}
+
+func (j *Jump) DelNode(name string) {
+	j.DelNodes(name)
+}
+
+// rebuild 按节点名称顺序重建桶, 调用方持有锁
+func (j *Jump) rebuild() {
+	names := make([]string, 0, len(j.nodes))
+	for name := range j.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	j.buckets = j.buckets[:0]
+	for _, name := range names {
+		for i := int32(0); i < j.nodes[name].Weight; i++ {
+			j.buckets = append(j.buckets, name)
+		}
+	}
+}
+
+func (j *Jump) GetNode(key string) (Node, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if err := checkKey(key, len(j.nodes)); err != nil {
+		return Node{}, err
+	}
+	return j.nodes[j.buckets[jump(hash64(key), len(j.buckets))]], nil
+}
+
+// GetNodes 以不同的种子重复查找直到得到 n 个不同的节点, 次数过多时按桶的顺序补足
+func (j *Jump) GetNodes(key string, n int) ([]Node, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if err := checkKey(key, len(j.nodes)); err != nil {
+		return nil, err
+	}
+	if n > len(j.nodes) {
+		n = len(j.nodes)
+	}
+	var (
+		nodes = make([]Node, 0, n)
+		seen  = make(map[string]bool, n)
+		h     = hash64(key)
+		add   = func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				nodes = append(nodes, j.nodes[name])
+			}
+		}
+	)
+	for i, seed := 0, h; len(nodes) < n && i < 8*len(j.buckets); i, seed = i+1, mix64(h+uint64(i+1)) {
+		add(j.buckets[jump(seed, len(j.buckets))])
+	}
+	for i := 0; len(nodes) < n; i++ {
+		add(j.buckets[i])
+	}
+	return nodes, nil
+}
+
+// jump 将 key 映射到 [0, buckets) 中的一个桶
+func jump(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,162 @@
+package consistent
+
+import (
+	"sort"
+	"sync"
+)
+
+const defaultTableSize = 65537
+
+// Maglev Google Maglev 查找表, 每个节点按权重轮流填充自己偏好的槽位
+// 查找为 O(1), 增删节点时重建整张表, 移动的 key 略多于理想情况
+type Maglev struct {
+	mu    sync.RWMutex
+	size  int // 查找表大小, 为素数
+	nodes map[string]Node
+	table []string // 槽位 -> 节点名称
+}
+
+// NewMaglev size 为查找表大小, 会调整为不小于 size 的素数, 不大于 0 时为 65537
+// size 应远大于节点数 (例如 100 倍) 以保证分布均匀
+func NewMaglev(size int) *Maglev {
+	if size <= 0 {
+		size = defaultTableSize
+	}
+	return &Maglev{
+		size:  nextPrime(size),
+		nodes: make(map[string]Node),
+	}
+}
+
+// AddNodes 批量添加节点, 只重建一次查找表
+func (m *Maglev) AddNodes(nodes ...Node) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, node := range nodes {
+		if node.Weight <= 0 {
+			node.Weight = 1
+		}
+		m.nodes[node.Name] = node
+	}
+	m.populate()
+}
+
+func (m *Maglev) AddNode(node Node) {
+	m.AddNodes(node)
+}
+
+// DelNodes 批量删除节点, 只重建一次查找表
+func (m *Maglev) DelNodes(names ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range names {
+		delete(m.nodes, name)
+	}
+	m.populate()
+}
+
+func (m *Maglev) DelNode(name string) {
+	m.DelNodes(name)
+}
+
+// populate 重建查找表, 调用方持有锁
+func (m *Maglev) populate() {
+	if len(m.nodes) == 0 {
+		m.table = nil
+		return
+	}
+	names := make([]string, 0, len(m.nodes))
+	for name := range m.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	size := uint64(m.size)
+	offsets := make([]uint64, len(names))
+	skips := make([]uint64, len(names))
+	for i, name := range names {
+		h := hash64(name)
+		offsets[i] = h % size
+		skips[i] = mix64(h)%(size-1) + 1
+	}
+
+	slots := make([]int, m.size)
+	for i := range slots {
+		slots[i] = -1
+	}
+	next := make([]uint64, len(names))
+	for filled := 0; filled < m.size; {
+		for i, name := range names {
+			for w := int32(0); w < m.nodes[name].Weight && filled < m.size; w++ {
+				c := (offsets[i] + next[i]*skips[i]) % size
+				for slots[c] >= 0 {
+					next[i]++
+					c = (offsets[i] + next[i]*skips[i]) % size
+				}
+				slots[c] = i
+				next[i]++
+				filled++
+			}
+		}
+	}
+
+	m.table = make([]string, m.size)
+	for i, slot := range slots {
+		m.table[i] = names[slot]
+	}
+}
+
+func (m *Maglev) GetNode(key string) (Node, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := checkKey(key, len(m.nodes)); err != nil {
+		return Node{}, err
+	}
+	return m.nodes[m.table[hash64(key)%uint64(m.size)]], nil
+}
+
+// GetNodes 从 key 的槽位开始顺序查找 n 个不同的节点
+func (m *Maglev) GetNodes(key string, n int) ([]Node, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := checkKey(key, len(m.nodes)); err != nil {
+		return nil, err
+	}
+	if n > len(m.nodes) {
+		n = len(m.nodes)
+	}
+	var (
+		nodes = make([]Node, 0, n)
+		seen  = make(map[string]bool, n)
+		idx   = int(hash64(key) % uint64(m.size))
+	)
+	for i := 0; i < m.size && len(nodes) < n; i++ {
+		name := m.table[(idx+i)%m.size]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		nodes = append(nodes, m.nodes[name])
+	}
+	return nodes, nil
+}
+
+func nextPrime(n int) int {
+	for ; ; n++ {
+		if isPrime(n) {
+			return n
+		}
+	}
+}
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
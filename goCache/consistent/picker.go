@@ -0,0 +1,69 @@
+package consistent
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Picker 节点放置算法, 决定 key 属于哪些节点
+type Picker interface {
+	AddNodes(nodes ...Node)                     // 批量添加节点, 已存在时更新
+	AddNode(node Node)                          // 添加节点, 已存在时更新
+	DelNodes(names ...string)                   // 批量删除节点
+	DelNode(name string)                        // 删除节点
+	GetNode(key string) (Node, error)           // key 所属的节点
+	GetNodes(key string, n int) ([]Node, error) // key 的前 n 个不同的节点, 第一个与 GetNode 相同
+}
+
+var (
+	_ Picker = (*Consistent)(nil)
+	_ Picker = (*Rendezvous)(nil)
+	_ Picker = (*Jump)(nil)
+	_ Picker = (*Maglev)(nil)
+	_ Picker = (*Bounded)(nil)
+)
+
+// NewPicker 按名称创建放置算法: ring (默认, 虚拟节点一致性hash), rendezvous, jump, maglev
+func NewPicker(name string) (Picker, error) {
+	switch name {
+	case "", "ring":
+		return New(0, nil), nil
+	case "rendezvous":
+		return NewRendezvous(), nil
+	case "jump":
+		return NewJump(), nil
+	case "maglev":
+		return NewMaglev(0), nil
+	}
+	return nil, fmt.Errorf("unknown picker: %s", name)
+}
+
+// hash64 计算 fnv-1a 64 并进行混合, 使相近的输入分布均匀
+func hash64(data ...string) uint64 {
+	h := fnv.New64a()
+	for _, d := range data {
+		h.Write([]byte(d))
+		h.Write([]byte{0})
+	}
+	return mix64(h.Sum64())
+}
+
+// mix64 splitmix64 的最终混合步骤
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func checkKey(key string, nodes int) error {
+	if len(key) == 0 {
+		return fmt.Errorf("key cannot be nil")
+	}
+	if nodes == 0 {
+		return fmt.Errorf("ring is nil")
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package consistent
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+var pickers = []string{"ring", "rendezvous", "jump", "maglev"}
+
+func newNodes(n int) []Node {
+	nodes := make([]Node, n)
+	for i := range nodes {
+		nodes[i] = Node{Name: fmt.Sprintf("node%d", i), Addr: fmt.Sprintf("localhost:%d", 8000+i), Weight: 1}
+	}
+	return nodes
+}
+
+func owners(p Picker, keys int) map[string]string {
+	m := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := "key" + strconv.Itoa(i)
+		node, _ := p.GetNode(key)
+		m[key] = node.Name
+	}
+	return m
+}
+
+func moved(before, after map[string]string) float64 {
+	var n int
+	for key, owner := range before {
+		if after[key] != owner {
+			n++
+		}
+	}
+	return float64(n) / float64(len(before))
+}
+
+func TestPicker(t *testing.T) {
+	for _, name := range pickers {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewPicker(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.GetNode("key"); err == nil {
+				t.Fatal("expected error on empty picker")
+			}
+			p.AddNodes(newNodes(5)...)
+			for i := 0; i < 100; i++ {
+				key := "key" + strconv.Itoa(i)
+				node, err := p.GetNode(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				nodes, err := p.GetNodes(key, 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(nodes) != 3 || nodes[0].Name != node.Name {
+					t.Fatalf("expected 3 nodes starting with %s, got %v", node.Name, nodes)
+				}
+				if nodes[0].Name == nodes[1].Name || nodes[1].Name == nodes[2].Name || nodes[0].Name == nodes[2].Name {
+					t.Fatalf("expected distinct nodes, got %v", nodes)
+				}
+			}
+			if nodes, _ := p.GetNodes("key", 10); len(nodes) != 5 {
+				t.Fatalf("expected all 5 nodes, got %d", len(nodes))
+			}
+			p.DelNode("node0")
+			for _, owner := range owners(p, 1000) {
+				if owner == "node0" {
+					t.Fatal("expected node0 to own no keys after delete")
+				}
+			}
+		})
+	}
+	if _, err := NewPicker("unknown"); err == nil {
+		t.Fatal("expected error for unknown picker")
+	}
+}
+
+func TestPicker_Weight(t *testing.T) {
+	for _, name := range pickers {
+		t.Run(name, func(t *testing.T) {
+			p, _ := NewPicker(name)
+			nodes := newNodes(4)
+			nodes[0].Weight = 3
+			p.AddNodes(nodes...)
+			counts := make(map[string]int)
+			for _, owner := range owners(p, 60000) {
+				counts[owner]++
+			}
+			// node0 期望 1/2 的 key
+			if share := float64(counts["node0"]) / 60000; share < 0.4 || share > 0.6 {
+				t.Fatalf("expected node0 to own about half of keys, got %.3f", share)
+			}
+		})
+	}
+}
+
+func TestPicker_InvalidWeight(t *testing.T) {
+	for _, name := range pickers {
+		t.Run(name, func(t *testing.T) {
+			p, _ := NewPicker(name)
+			nodes := newNodes(2)
+			nodes[0].Weight = -3
+			nodes[1].Weight = 0
+			// 不大于 0 的权重视为 1
+			p.AddNodes(nodes...)
+			counts := make(map[string]int)
+			for _, owner := range owners(p, 10000) {
+				counts[owner]++
+			}
+			if share := float64(counts["node0"]) / 10000; share < 0.35 || share > 0.65 {
+				t.Fatalf("expected node0 to own about half of keys, got %.3f", share)
+			}
+		})
+	}
+}
+
+func TestPicker_Order(t *testing.T) {
+	for _, name := range pickers {
+		t.Run(name, func(t *testing.T) {
+			nodes := newNodes(5)
+			nodes[1].Weight = 3
+			a, _ := NewPicker(name)
+			a.AddNodes(nodes...)
+
+			// 以不同的顺序逐个添加, 中途删除再重新加入
+			b, _ := NewPicker(name)
+			for i := len(nodes) - 1; i >= 0; i-- {
+				b.AddNode(nodes[i])
+			}
+			b.DelNodes(nodes[0].Name, nodes[3].Name)
+			b.AddNodes(nodes[3], nodes[0])
+
+			ownersA, ownersB := owners(a, 1000), owners(b, 1000)
+			for key, owner := range ownersA {
+				if ownersB[key] != owner {
+					t.Fatalf("expected %s to map to %s regardless of order, got %s", key, owner, ownersB[key])
+				}
+			}
+		})
+	}
+}
+
+func TestPicker_Movement(t *testing.T) {
+	for _, name := range pickers {
+		t.Run(name, func(t *testing.T) {
+			// jump 按名称重建桶, 增删节点时名称之后的桶整体移动, 不做检查
+			if name == "jump" {
+				t.Skip("jump trades movement for order independence")
+			}
+			p, _ := NewPicker(name)
+			p.AddNodes(newNodes(10)...)
+			before := owners(p, 20000)
+
+			// 理想情况下移动 1/11 的 key
+			p.AddNode(Node{Name: "node10", Addr: "localhost:8010", Weight: 1})
+			added := owners(p, 20000)
+			if m := moved(before, added); m > 0.2 {
+				t.Fatalf("expected few keys to move on add, got %.3f", m)
+			}
+			// maglev 重建查找表时少量槽位会在原有节点间移动, 不做检查
+			for key, owner := range added {
+				if name != "maglev" && owner != before[key] && owner != "node10" {
+					t.Fatalf("expected keys to move only to the new node, %s moved to %s", key, owner)
+				}
+			}
+
+			// 理想情况下移动 1/11 的 key
+			p.DelNode("node3")
+			if m := moved(added, owners(p, 20000)); m > 0.25 {
+				t.Fatalf("expected few keys to move on delete, got %.3f", m)
+			}
+		})
+	}
+}
+
+func BenchmarkPicker_GetNode(b *testing.B) {
+	for _, name := range pickers {
+		for _, n := range []int{10, 100, 1000} {
+			b.Run(fmt.Sprintf("%s/%d", name, n), func(b *testing.B) {
+				p, _ := NewPicker(name)
+				p.AddNodes(newNodes(n)...)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					p.GetNode("key" + strconv.Itoa(i&1023))
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkPicker_Movement 报告增删一个节点时移动的 key 比例, 理想值为 1/(n+1)
+func BenchmarkPicker_Movement(b *testing.B) {
+	for _, name := range pickers {
+		b.Run(name, func(b *testing.B) {
+			var add, del float64
+			for i := 0; i < b.N; i++ {
+				p, _ := NewPicker(name)
+				p.AddNodes(newNodes(10)...)
+				before := owners(p, 10000)
+				p.AddNode(Node{Name: "node10", Addr: "localhost:8010", Weight: 1})
+				added := owners(p, 10000)
+				p.DelNode("node3")
+				add += moved(before, added)
+				del += moved(added, owners(p, 10000))
+			}
+			b.ReportMetric(add/float64(b.N), "add-moved/op")
+			b.ReportMetric(del/float64(b.N), "del-moved/op")
+		})
+	}
+}
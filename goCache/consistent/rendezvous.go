@@ -0,0 +1,101 @@
+package consistent
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Rendezvous 最高随机权重 (HRW) 算法, key 属于得分最高的节点
+// 增删节点只影响该节点上的 key, 查找为 O(n)
+type Rendezvous struct {
+	mu    sync.RWMutex
+	nodes map[string]Node
+}
+
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{
+		nodes: make(map[string]Node),
+	}
+}
+
+func (r *Rendezvous) AddNodes(nodes ...Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range nodes {
+		if node.Weight <= 0 {
+			node.Weight = 1
+		}
+		r.nodes[node.Name] = node
+	}
+}
+
+func (r *Rendezvous) AddNode(node Node) {
+	r.AddNodes(node)
+}
+
+func (r *Rendezvous) DelNodes(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range names {
+		delete(r.nodes, name)
+	}
+}
+
+func (r *Rendezvous) DelNode(name string) {
+	r.DelNodes(name)
+}
+
+func (r *Rendezvous) GetNode(key string) (Node, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if err := checkKey(key, len(r.nodes)); err != nil {
+		return Node{}, err
+	}
+	var (
+		best      Node
+		bestScore = math.Inf(-1)
+	)
+	for _, node := range r.nodes {
+		if s := score(key, node); s > bestScore || (s == bestScore && node.Name < best.Name) {
+			best, bestScore = node, s
+		}
+	}
+	return best, nil
+}
+
+func (r *Rendezvous) GetNodes(key string, n int) ([]Node, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if err := checkKey(key, len(r.nodes)); err != nil {
+		return nil, err
+	}
+	type scored struct {
+		node  Node
+		score float64
+	}
+	all := make([]scored, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		all = append(all, scored{node: node, score: score(key, node)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score == all[j].score {
+			return all[i].node.Name < all[j].node.Name
+		}
+		return all[i].score > all[j].score
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+	nodes := make([]Node, n)
+	for i := range nodes {
+		nodes[i] = all[i].node
+	}
+	return nodes, nil
+}
+
+// score 加权得分 -weight / ln(u), u 为 (0, 1) 上均匀分布的 hash 值
+func score(key string, node Node) float64 {
+	u := (float64(hash64(key, node.Name)>>11) + 0.5) / (1 << 53)
+	return -float64(node.Weight) / math.Log(u)
+}
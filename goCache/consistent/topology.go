@@ -0,0 +1,46 @@
+package consistent
+
+// Spread 从按优先级排列的 nodes 中选择 n 个节点, 依次优先选择不同的 zone、rack、host,
+// 使一个可用区、机架或主机故障时不会同时失去所有副本
+// 第一个节点总是被选中, 返回的节点保持原来的优先级顺序
+func Spread(nodes []Node, n int) []Node {
+	if n >= len(nodes) {
+		return nodes
+	}
+	var (
+		chosen = make([]bool, len(nodes))
+		count  int
+	)
+	levels := []func(node Node) string{
+		func(node Node) string { return node.Zone },
+		func(node Node) string { return node.Zone + "/" + node.Rack },
+		func(node Node) string { return node.Zone + "/" + node.Rack + "/" + node.Host },
+		func(node Node) string { return node.Name },
+	}
+	for _, level := range levels {
+		used := make(map[string]bool, n)
+		for i, node := range nodes {
+			if chosen[i] {
+				used[level(node)] = true
+			}
+		}
+		for i, node := range nodes {
+			if count == n {
+				break
+			}
+			if chosen[i] || used[level(node)] {
+				continue
+			}
+			chosen[i] = true
+			used[level(node)] = true
+			count++
+		}
+	}
+	result := make([]Node, 0, n)
+	for i, node := range nodes {
+		if chosen[i] {
+			result = append(result, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+package consistent
+
+import (
+	"testing"
+)
+
+func names(nodes []Node) string {
+	var s string
+	for i, node := range nodes {
+		if i > 0 {
+			s += ","
+		}
+		s += node.Name
+	}
+	return s
+}
+
+func TestSpread(t *testing.T) {
+	nodes := []Node{
+		{Name: "a1", Zone: "a", Rack: "r1"},
+		{Name: "a2", Zone: "a", Rack: "r1"},
+		{Name: "a3", Zone: "a", Rack: "r2"},
+		{Name: "b1", Zone: "b", Rack: "r1"},
+		{Name: "c1", Zone: "c", Rack: "r1"},
+	}
+	for _, c := range []struct {
+		n    int
+		want string
+	}{
+		{1, "a1"},
+		// 优先选择不同的 zone
+		{3, "a1,b1,c1"},
+		// zone 用完后选择不同的 rack
+		{4, "a1,a3,b1,c1"},
+		{5, "a1,a2,a3,b1,c1"},
+		{10, "a1,a2,a3,b1,c1"},
+	} {
+		if got := names(Spread(nodes, c.n)); got != c.want {
+			t.Errorf("Spread(%d) expected %s, got %s", c.n, c.want, got)
+		}
+	}
+
+	// 没有拓扑标签时与原来的顺序相同
+	if got := names(Spread(newNodes(5), 3)); got != "node0,node1,node2" {
+		t.Errorf("expected first 3 nodes without labels, got %s", got)
+	}
+}
@@ -1,7 +1,10 @@
 package goCache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"goCache/goCache/cache"
 	"goCache/goCache/singleflight"
 	"log"
 	"math/rand"
@@ -23,12 +26,47 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// ContextGetter 支持 context 的 Getter, 可以感知调用方的超时与取消
+type ContextGetter interface {
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// getterAdapter 将 Getter 适配为 ContextGetter, ctx 被忽略
+type getterAdapter struct {
+	Getter
+}
+
+func (g getterAdapter) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return g.Get(key)
+}
+
+// batchGetterOf 判断 getter 是否同时实现了 BatchGetter
+func batchGetterOf(getter ContextGetter) BatchGetter {
+	if a, ok := getter.(getterAdapter); ok {
+		b, _ := a.Getter.(BatchGetter)
+		return b
+	}
+	b, _ := getter.(BatchGetter)
+	return b
+}
+
 type Group struct {
-	name   string
-	getter Getter
+	name        string
+	getter      ContextGetter
+	batchGetter BatchGetter
 	CacheOption
-	peer   Peer
-	loader singleflight.Flight
+	peer      Peer
+	loader    singleflight.Flight
+	refresh   sync.Map // 正在后台刷新的 key
+	refresher *refresher
+	janitors  []*cache.Janitor
+	stats     groupStats
 }
 
 var (
@@ -46,36 +84,169 @@ func GetGroup(group string) (*Group, bool) {
 }
 
 func NewGroup(name string, getter Getter, options ...CacheOptionFunc) *Group {
+	if g, ok := getter.(ContextGetter); ok {
+		return NewGroupContext(name, g, options...)
+	}
+	return NewGroupContext(name, getterAdapter{getter}, options...)
+}
+
+func NewGroupContext(name string, getter ContextGetter, options ...CacheOptionFunc) *Group {
 	mu.Lock()
 	defer mu.Unlock()
 	cache := &Group{
 		name:        name,
 		getter:      getter,
+		batchGetter: batchGetterOf(getter),
 		CacheOption: DefaultCacheOption(),
 	}
 	for _, op := range options {
 		op(&cache.CacheOption)
 	}
+	cache.CacheOption.init()
+	cache.startJanitors()
+	cache.startRefresher()
 	groups[name] = cache
 	return cache
 }
 
+func (c *Group) startJanitors() {
+	if c.sweepInterval <= 0 {
+		return
+	}
+	for _, cc := range []cache.Cache{c.mainCache, c.hotCache} {
+		if e, ok := cc.(cache.Expirer); ok {
+			c.janitors = append(c.janitors, cache.NewJanitor(e, c.sweepInterval))
+		}
+	}
+}
+
+// Close 停止 group 的后台任务
+func (c *Group) Close() {
+	for _, j := range c.janitors {
+		j.Close()
+	}
+	if c.refresher != nil {
+		c.refresher.close()
+	}
+}
+
 func (c *Group) Get(key string) (ByteView, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext 获取缓存, ctx 结束时立即返回; 加载由同一 key 的调用者共享, 只有所有调用者都返回后才取消
+func (c *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
+	c.stats.gets.Add(1)
 	if v, exist := c.lookupCache(key); exist {
+		c.track(key)
+		c.revalidate(key, v)
 		return v, nil
 	}
-	return c.load(key)
+	if c.lookupNegative(key) {
+		return ByteView{}, notFound(key)
+	}
+	return c.load(ctx, key)
+}
+
+// getLocally 处理其他节点转发的请求, 未命中时在本节点加载, 不再转发
+func (c *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	c.stats.gets.Add(1)
+	if v, exist := c.lookupCache(key); exist {
+		c.track(key)
+		c.revalidate(key, v)
+		return v, nil
+	}
+	if c.lookupNegative(key) {
+		return ByteView{}, notFound(key)
+	}
+	return c.loadLocallyOnce(ctx, key)
 }
 
-func (c *Group) Set(key string, value []byte, expire time.Duration) {
-	c.mainCache.Set(key, ByteView{value}, expire)
+// loadLocallyOnce 通过 singleflight 在本节点加载
+func (c *Group) loadLocallyOnce(ctx context.Context, key string) (ByteView, error) {
+	value, err := c.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return c.loadLocally(ctx, key)
+	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return value.(ByteView), nil
+}
+
+func (c *Group) Set(key string, value []byte, expire time.Duration) error {
+	return c.SetContext(context.Background(), key, value, expire)
+}
+
+// SetContext 写入 key 所属的节点及其副本
+func (c *Group) SetContext(ctx context.Context, key string, value []byte, expire time.Duration) error {
+	c.deleteNegative(key)
+	owners := c.owners(key)
+	if len(owners) == 0 {
+		c.setLocally(key, value, expire)
+		return nil
+	}
+	var errs []error
+	for _, peer := range owners {
+		if peer == nil {
+			c.setLocally(key, value, expire)
+			continue
+		}
+		err := peer.Set(ctx, c.name, key, value, expire)
+		peerRequests.Inc(c.name, peer.Name(), "set", result(err))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to set to peer %s, err: %w", peer.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Group) setLocally(key string, value []byte, expire time.Duration) {
+	c.deleteNegative(key)
+	v := ByteView{b: value}
+	if expire > 0 {
+		v.expire = time.Now().Add(expire).UnixMilli()
+	}
+	c.mainCache.Set(key, v, expire)
 }
 
 func (c *Group) Remove(key string) error {
-	if _, exist := c.lookupCache(key); exist {
+	return c.RemoveContext(context.Background(), key)
+}
+
+func (c *Group) RemoveContext(ctx context.Context, key string) error {
+	if c.replicas > 1 {
+		return c.removeReplicas(ctx, key)
+	}
+	if c.cached(key) {
 		return c.removeLocally(key)
 	}
-	return c.removeFromPeer(key)
+	return c.removeFromPeer(ctx, key)
+}
+
+// removeReplicas 从所有副本删除, 副本中不存在 key 不视为错误
+func (c *Group) removeReplicas(ctx context.Context, key string) error {
+	c.mainCache.Delete(key)
+	c.hotCache.Delete(key)
+	var errs []error
+	for _, peer := range c.owners(key) {
+		if peer == nil {
+			continue
+		}
+		err := peer.Remove(ctx, c.name, key)
+		peerRequests.Inc(c.name, peer.Name(), "remove", result(err))
+		if err != nil && unreachable(err) {
+			errs = append(errs, fmt.Errorf("failed to remove from peer %s, err: %w", peer.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// owners 返回 key 所属的节点及其副本, 自身节点为 nil
+func (c *Group) owners(key string) []PeerGetter {
+	if c.peer == nil {
+		return nil
+	}
+	return c.peer.PickPeers(key, c.replicas)
 }
 
 func (c *Group) removeLocally(key string) error {
@@ -86,17 +257,32 @@ func (c *Group) removeLocally(key string) error {
 	return nil
 }
 
-func (c *Group) removeFromPeer(key string) error {
+func (c *Group) removeFromPeer(ctx context.Context, key string) error {
+	if c.peer == nil {
+		return fmt.Errorf("picker not have")
+	}
 	peer, ok := c.peer.PickPeer(key)
 	if !ok {
 		return fmt.Errorf("picker not have")
 	}
-	return peer.Remove(c.name, key)
+	err := peer.Remove(ctx, c.name, key)
+	peerRequests.Inc(c.name, peer.Name(), "remove", result(err))
+	return err
+}
+
+// cached 判断 key 是否在本地缓存中, 不计入命中统计
+func (c *Group) cached(key string) bool {
+	if _, ok := c.mainCache.Get(key); ok {
+		return true
+	}
+	_, ok := c.hotCache.Get(key)
+	return ok
 }
 
 func (c *Group) lookupCache(key string) (ByteView, bool) {
 	v, ok := c.mainCache.Get(key)
 	if ok {
+		c.stats.mainCacheHits.Add(1)
 		log.Printf("[%s] lookup cache, mainCache hit\n", c.name)
 		return v.(ByteView), ok
 	}
@@ -105,44 +291,109 @@ func (c *Group) lookupCache(key string) (ByteView, bool) {
 
 		return ByteView{}, false
 	}
+	c.stats.hotCacheHits.Add(1)
 	log.Printf("[%s] lookup cache, hotCache hit\n", c.name)
 	return v.(ByteView), ok
 }
 
-func (c *Group) load(key string) (ByteView, error) {
-	value, err := c.loader.Do(key, func() (interface{}, error) {
-		peer, ok := c.peer.PickPeer(key)
-		if ok {
-			return c.loadFromPeer(key, peer)
-		}
-		return c.loadLocally(key)
+func (c *Group) load(ctx context.Context, key string) (ByteView, error) {
+	value, err := c.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return c.loadFromOwners(ctx, key)
 	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return value.(ByteView), nil
+}
 
-	return value.(ByteView), err
+// loadFromOwners 按优先级从 key 所属的节点加载, 节点不可达时尝试下一个副本
+func (c *Group) loadFromOwners(ctx context.Context, key string) (ByteView, error) {
+	owners := c.owners(key)
+	if len(owners) == 0 {
+		return c.loadLocally(ctx, key)
+	}
+	owners = c.remoteFirst(key, owners)
+	var err error
+	for _, peer := range owners {
+		if peer == nil {
+			return c.loadLocally(ctx, key)
+		}
+		var v ByteView
+		v, err = c.loadFromPeer(ctx, key, peer)
+		if err == nil || !unreachable(err) || ctx.Err() != nil {
+			return v, err
+		}
+		log.Printf("[%s] peer %s unreachable, try next replica\n", c.name, peer.Name())
+	}
+	return ByteView{}, err
 }
 
-func (c *Group) loadLocally(key string) (ByteView, error) {
+// remoteFirst 本节点是副本而不是主节点时, 本地未命中先从主节点加载, 再按原顺序 (同 zone 优先) 尝试其他副本,
+// 都不可达时才由本节点加载, 避免每个副本都访问数据源
+func (c *Group) remoteFirst(key string, owners []PeerGetter) []PeerGetter {
+	replica := false
+	for _, peer := range owners {
+		if peer == nil {
+			replica = true
+		}
+	}
+	if !replica {
+		return owners
+	}
+	primary, ok := c.peer.PickPeer(key)
+	if !ok {
+		return owners
+	}
+	ordered := append(make([]PeerGetter, 0, len(owners)+1), primary)
+	for _, peer := range owners {
+		if peer != nil && peer.Name() != primary.Name() {
+			ordered = append(ordered, peer)
+		}
+	}
+	return append(ordered, nil)
+}
+
+func (c *Group) loadLocally(ctx context.Context, key string) (ByteView, error) {
 	log.Println("load locally")
-	v, err := c.getter.Get(key)
+	c.stats.localLoads.Add(1)
+	start := time.Now()
+	v, err := c.getter.GetContext(ctx, key)
+	getterLatency.Since(start, c.name)
+	if errors.Is(err, ErrNotFound) {
+		c.setNegative(key)
+		return ByteView{}, err
+	}
 	if err != nil {
+		c.stats.loaderErrors.Add(1)
 		return ByteView{}, err
 	}
-	c.Set(key, v, defaultExpire)
-	return ByteView{b: v}, nil
+	return c.setLoaded(key, v), nil
 }
 
-func (c *Group) loadFromPeer(key string, peer PeerGetter) (ByteView, error) {
+func (c *Group) loadFromPeer(ctx context.Context, key string, peer PeerGetter) (ByteView, error) {
 	log.Println("load peer, ", peer.Name())
-	data, err := peer.Get(c.name, key)
+	c.stats.peerLoads.Add(1)
+	start := time.Now()
+	data, err := peer.Get(ctx, c.name, key)
+	peerLatency.Since(start, c.name, peer.Name())
+	peerRequests.Inc(c.name, peer.Name(), "get", result(err))
+	if errors.Is(err, ErrNotFound) {
+		c.setNegative(key)
+		return ByteView{}, err
+	}
 	if err != nil {
+		c.stats.peerErrors.Add(1)
 		return ByteView{}, err
 	}
 	if rand.Intn(10) == 0 {
-		c.hotCache.Set(key, ByteView{b: data}, defaultExpire)
+		c.setHot(key, data)
 	}
 	return ByteView{b: data}, nil
 }
 
 func (c *Group) RegisterPeer(peer Peer) {
 	c.peer = peer
+	if r, ok := peer.(rebalancer); ok {
+		r.onRebalance(c.rebalance)
+	}
 }
@@ -1,13 +1,17 @@
 package goCache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"goCache/goCache/registry"
 	"log"
 	"net/http"
 	"net/url"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var db = map[string]string{
@@ -18,12 +22,11 @@ var db = map[string]string{
 // TestGroup_Breakdown 测试single flight解决缓存击穿
 func TestGroup_Breakdown(t *testing.T) {
 	var (
-		addr     = "http://localhost:8000"
-		etcdAddr = ""
-		cnt      atomic.Int32
+		addr = "http://localhost:8010"
+		cnt  atomic.Int32
 	)
 	// 初始化peer
-	peer := NewHTTPPool(addr, etcdAddr)
+	peer := NewHTTPPoolWithOptions(addr, nil, WithPeerOptionsRegistry(registry.NewStatic()))
 	peer.StartService()
 	// 初始化group
 	group := NewGroup("score", GetterFunc(func(key string) ([]byte, error) {
@@ -37,15 +40,12 @@ func TestGroup_Breakdown(t *testing.T) {
 	// 为group 注册 peer
 	group.RegisterPeer(peer)
 
-	u, err := url.JoinPath(addr, "score", "Tom")
-	if err != nil {
-		t.Fatalf("join get url failed, err: %v", err)
-	}
+	getter := NewHTTPGetter("test", addr)
 	wg := sync.WaitGroup{}
 	wg.Add(100)
 	for i := 0; i < 100; i++ {
 		go func() {
-			http.Get(u)
+			getter.Get(context.Background(), "score", "Tom")
 			wg.Done()
 		}()
 	}
@@ -65,12 +65,11 @@ func TestGroup_Breakdown(t *testing.T) {
 // 缓存穿透问题应该在业务层解决
 func TestGroup_Penetration(t *testing.T) {
 	var (
-		addr     = "http://localhost:8000"
-		etcdAddr = ""
-		cnt      atomic.Int32
+		addr = "http://localhost:8011"
+		cnt  atomic.Int32
 	)
 	// 初始化peer
-	peer := NewHTTPPool(addr, etcdAddr)
+	peer := NewHTTPPoolWithOptions(addr, nil, WithPeerOptionsRegistry(registry.NewStatic()))
 	peer.StartService()
 	// 初始化group
 	group := NewGroup("score", GetterFunc(func(key string) ([]byte, error) {
@@ -87,14 +86,14 @@ func TestGroup_Penetration(t *testing.T) {
 	wg := sync.WaitGroup{}
 	wg.Add(10)
 	for i := 0; i < 10; i++ {
-		go func(i int) {
-			u, err := url.JoinPath(addr, "score", fmt.Sprintf("TEST-%d", i))
-			if err != nil {
-				t.Fatalf("join get url failed, err: %v", err)
-			}
+		u, err := url.JoinPath(addr, "score", fmt.Sprintf("TEST-%d", i))
+		if err != nil {
+			t.Fatalf("join get url failed, err: %v", err)
+		}
+		go func() {
 			http.Get(u)
 			wg.Done()
-		}(i)
+		}()
 	}
 	wg.Wait()
 
@@ -105,3 +104,125 @@ func TestGroup_Penetration(t *testing.T) {
 	t.Log(cnt.Load())
 
 }
+
+func TestGroup_Stats(t *testing.T) {
+	group := NewGroup("stats", GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("[Slow DB] not have")
+	}), WithCacheOptionsStrategy("lru", 1<<10, nil))
+
+	group.Get("Tom")
+	group.Get("Tom")
+	group.Get("Jack")
+	group.Get("unknown")
+
+	stats := group.Stats()
+	if stats.Gets != 4 || stats.MainCacheHits != 1 || stats.LocalLoads != 3 || stats.LoaderErrors != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.MainCache.Items != 2 {
+		t.Fatalf("expected 2 items in mainCache, got %d", stats.MainCache.Items)
+	}
+}
+
+func TestGroup_GetContext(t *testing.T) {
+	group := NewGroupContext("context", ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err := group.GetContext(ctx, "Tom")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+// replicaPeer 所有 key 属于相同的 owners, primary 为空时 owners 的第一个为主节点
+type replicaPeer struct {
+	fakePeer
+	owners  []PeerGetter
+	primary PeerGetter
+}
+
+func (r *replicaPeer) PickPeer(key string) (PeerGetter, bool) {
+	if r.primary != nil {
+		return r.primary, true
+	}
+	if r.owners[0] == nil {
+		return nil, false
+	}
+	return r.owners[0], true
+}
+
+func (r *replicaPeer) PickPeers(key string, n int) []PeerGetter {
+	if n > len(r.owners) {
+		n = len(r.owners)
+	}
+	return r.owners[:n]
+}
+
+func TestGroup_Replicas(t *testing.T) {
+	var (
+		cnt     atomic.Int32
+		down    = &fakeGetter{name: "down", err: &url.Error{Op: "Get", URL: "http://down", Err: errors.New("connection refused")}}
+		replica = &fakeGetter{name: "replica"}
+	)
+	group := NewGroup("replicas", GetterFunc(func(key string) ([]byte, error) {
+		cnt.Add(1)
+		return []byte("db-" + key), nil
+	}), WithCacheOptionsReplicas(3))
+
+	// 主节点不可达时读取下一个副本
+	group.RegisterPeer(&replicaPeer{owners: []PeerGetter{down, replica, nil}})
+	v, err := group.Get("key1")
+	if err != nil || v.String() != "key1" {
+		t.Fatalf("expected value from replica, got %s, err: %v", v, err)
+	}
+	if cnt.Load() != 0 {
+		t.Fatalf("expected no local load, got %d", cnt.Load())
+	}
+
+	// 其余副本都不可达时由本节点加载
+	group.RegisterPeer(&replicaPeer{owners: []PeerGetter{down, nil}})
+	if v, err = group.Get("key2"); err != nil || v.String() != "db-key2" {
+		t.Fatalf("expected value from local getter, got %s, err: %v", v, err)
+	}
+
+	// 本节点是副本时, 本地未命中先从主节点加载, 而不是排在最前的本节点直接访问数据源
+	primary := &fakeGetter{name: "primary"}
+	group.RegisterPeer(&replicaPeer{owners: []PeerGetter{nil, replica, primary}, primary: primary})
+	if v, err = group.Get("key5"); err != nil || v.String() != "key5" {
+		t.Fatalf("expected value from primary, got %s, err: %v", v, err)
+	}
+	if primary.gets.Load() != 1 || cnt.Load() != 1 {
+		t.Fatalf("expected 1 request to the primary and no more local loads, got %d, %d", primary.gets.Load(), cnt.Load())
+	}
+	// 主节点不可达时尝试其他副本
+	replica.gets.Store(0)
+	group.RegisterPeer(&replicaPeer{owners: []PeerGetter{nil, replica, down}, primary: down})
+	if v, err = group.Get("key6"); err != nil || v.String() != "key6" || replica.gets.Load() != 1 {
+		t.Fatalf("expected value from replica, got %s, err: %v", v, err)
+	}
+
+	// 非不可达的错误不尝试其他副本
+	group.RegisterPeer(&replicaPeer{owners: []PeerGetter{&fakeGetter{err: errors.New("not found")}, replica}})
+	if _, err = group.Get("key3"); err == nil {
+		t.Fatal("expected error from primary")
+	}
+
+	// Set 写入所有副本
+	group.RegisterPeer(&replicaPeer{owners: []PeerGetter{replica, nil, down}})
+	if err = group.Set("key4", []byte("v4"), time.Minute); err == nil {
+		t.Fatal("expected error from unreachable replica")
+	}
+	if string(replica.sets["key4"]) != "v4" {
+		t.Fatalf("expected key4 to be replicated, got %v", replica.sets)
+	}
+	if v, ok := group.mainCache.Get("key4"); !ok || v.(ByteView).String() != "v4" {
+		t.Fatal("expected key4 to be set locally")
+	}
+}
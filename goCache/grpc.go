@@ -2,28 +2,25 @@ package goCache
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"go.etcd.io/etcd/api/v3/mvccpb"
-	clientv3 "go.etcd.io/etcd/client/v3"
-	"goCache/goCache/consistent"
 	"goCache/pb"
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"log"
 	"net"
-	"sync"
+	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type GrpcPeer struct {
-	registerCli    *clientv3.Client // 注册中心Client
-	discoveryCli   *clientv3.Client
-	leaseRespChan  <-chan *clientv3.LeaseKeepAliveResponse
-	self           string // 自身地址
-	weight         int32  // 该节点权重
-	mu             sync.RWMutex
-	consistentHash *consistent.Consistent // 一致性hash
-	getters        map[string]PeerGetter
+	*cluster
+	option PeerOption
+	server *grpc.Server
+	admin  *http.Server
 	pb.UnimplementedPeerServer
 }
 
@@ -36,7 +33,10 @@ func (g *GrpcPeer) Get(ctx context.Context, request *pb.GetRequest) (*pb.GetResp
 	if !ok {
 		return nil, fmt.Errorf("failed to get cache group, group:%s key:%s", request.GetGroup(), request.GetKey())
 	}
-	value, err := cache.Get(request.GetKey())
+	value, err := cache.getLocally(ctx, request.GetKey())
+	if errors.Is(err, ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -47,13 +47,26 @@ func (g *GrpcPeer) Get(ctx context.Context, request *pb.GetRequest) (*pb.GetResp
 	}, nil
 }
 
+func (g *GrpcPeer) GetMany(ctx context.Context, request *pb.GetManyRequest) (*pb.GetManyResponse, error) {
+	cache, ok := GetGroup(request.GetGroup())
+	if !ok {
+		return nil, fmt.Errorf("failed to get cache group, group: %s", request.GetGroup())
+	}
+	values, notFound, err := cache.getManyLocally(ctx, request.GetKeys())
+	return getManyResponse(request.GetKeys(), values, notFound, err), nil
+}
+
 func (g *GrpcPeer) Set(ctx context.Context, request *pb.SetRequest) (*pb.SetResponse, error) {
 	cache, ok := GetGroup(request.GetGroup())
 	if !ok {
 		return nil, fmt.Errorf("failed to get cache group, group: %s", request.GetGroup())
 	}
 
-	cache.Set(request.GetKey(), request.GetValue(), time.Duration(request.GetExpire()))
+	if request.GetLoaded() {
+		cache.setHandoff(request.GetKey(), request.GetValue(), time.Duration(request.GetSoft()), time.Duration(request.GetExpire()))
+	} else {
+		cache.setLocally(request.GetKey(), request.GetValue(), time.Duration(request.GetExpire()))
+	}
 
 	return &pb.SetResponse{Msg: ""}, nil
 }
@@ -63,7 +76,7 @@ func (g *GrpcPeer) Del(ctx context.Context, request *pb.DelRequest) (*pb.DelResp
 	if !ok {
 		return nil, fmt.Errorf("failed to get cache group, group: %s", request.GetGroup())
 	}
-	err := cache.Remove(request.GetKey())
+	err := cache.removeLocally(request.GetKey())
 	if err != nil {
 		return nil, err
 	}
@@ -71,152 +84,45 @@ func (g *GrpcPeer) Del(ctx context.Context, request *pb.DelRequest) (*pb.DelResp
 }
 
 func NewGrpcPeer(addr string, endpoints ...string) *GrpcPeer {
-	cli1, err := clientv3.New(clientv3.Config{
-		Endpoints: endpoints,
-	})
-	if err != nil {
-		panic(fmt.Errorf("failed to new etcd client, err: %v", err))
-	}
-	cli2, err := clientv3.New(clientv3.Config{
-		Endpoints: endpoints,
-	})
-	if err != nil {
-		panic(fmt.Errorf("failed to new etcd client, err: %v", err))
-	}
-
-	return &GrpcPeer{
-		registerCli:    cli1,
-		discoveryCli:   cli2,
-		self:           addr,
-		weight:         1,
-		consistentHash: consistent.New(0, nil),
-		getters:        make(map[string]PeerGetter),
-	}
+	return NewGrpcPeerWithOptions(addr, endpoints)
 }
 
-func (g *GrpcPeer) Register(prefix string, leaseExpire int64) {
-	// 创建租约
-	lease, err := g.registerCli.Grant(context.TODO(), leaseExpire)
-	if err != nil {
-		panic(fmt.Errorf("failed grant lease, err: %v", err))
-	}
-
-	// 设置租约不过期
-	leaseKeepaliveResp, err := g.registerCli.KeepAlive(context.TODO(), lease.ID)
-	if err != nil {
-		panic(err)
+// NewGrpcPeerWithOptions 创建 GrpcPeer, 未指定注册中心时使用 endpoints 对应的 etcd
+func NewGrpcPeerWithOptions(addr string, endpoints []string, options ...PeerOptionFunc) *GrpcPeer {
+	option := DefaultPeerOption()
+	for _, op := range options {
+		op(&option)
 	}
-	g.leaseRespChan = leaseKeepaliveResp
-	// 进行注册
-	key := fmt.Sprintf("%s-%d", prefix, lease.ID)
-	value, err := proto.Marshal(&pb.ServiceNode{
-		Name:   g.self,
-		Addr:   g.self,
-		Weight: g.weight,
+	option.initRegistry(endpoints)
+	g := &GrpcPeer{option: option}
+	g.cluster = newCluster(addr, option, func(name string, addr string) (PeerGetter, error) {
+		return NewGrpcGetter(name, addr, option.poolSize, option.grpcDialOptions()...)
 	})
-	if err != nil {
-		panic(fmt.Errorf("failed to marshal ServiceNode, err: %v", err))
-	}
-	_, err = g.registerCli.Put(context.TODO(), key, string(value), clientv3.WithLease(lease.ID))
-	if err != nil {
-		panic(fmt.Errorf("register service to etcd failed, err: %v", err))
-	}
-	go g.ListenLeaseResp()
-}
-
-func (g *GrpcPeer) ListenLeaseResp() {
-	for _ = range g.leaseRespChan {
-		//log.Println("租约续租成功", resp)
-	}
-	log.Println("关闭租约")
-}
-
-func (g *GrpcPeer) Discovery(prefix string) {
-	// 初始获取服务节点
-	resp, err := g.discoveryCli.Get(context.TODO(), prefix, clientv3.WithPrefix())
-	if err != nil {
-		panic(err)
-	}
-	for _, kv := range resp.Kvs {
-		g.SetService(string(kv.Key), string(kv.Value))
-	}
-
-	// 开启监听注册中心
-	go g.watch(g.discoveryCli, prefix)
-}
-
-func (g *GrpcPeer) watch(cli *clientv3.Client, prefix string) {
-	watchCh := cli.Watch(context.TODO(), prefix, clientv3.WithPrefix())
-	for resp := range watchCh {
-		for _, ev := range resp.Events {
-			switch ev.Type {
-			case mvccpb.PUT:
-				g.SetService(string(ev.Kv.Key), string(ev.Kv.Value))
-			case mvccpb.DELETE:
-				g.DelService(string(ev.Kv.Key))
-			}
-		}
-	}
-}
-
-func (g *GrpcPeer) SetService(key string, value string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	log.Println("add server node, ", key)
-	// 一致性hash节点添加
-	t := &pb.ServiceNode{}
-	err := proto.Unmarshal([]byte(value), t)
-	if err != nil {
-		panic(err)
-	}
-	g.consistentHash.AddNode(consistent.Node{
-		Name:   t.GetName(),
-		Addr:   t.GetAddr(),
-		Weight: t.GetWeight(),
-	})
-	// PeerGetter 添加
-	g.getters[t.GetName()] = NewGrpcGetter(t.GetName(), t.GetAddr())
-}
-
-func (g *GrpcPeer) DelService(key string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	log.Println("del server node, ", key)
-	g.consistentHash.DelNode(key)
-	delete(g.getters, key)
-}
-
-func (g *GrpcPeer) PickPeer(key string) (PeerGetter, bool) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	node, err := g.consistentHash.GetNode(key)
-	if err != nil || node.Addr == g.self {
-		return nil, false
-	}
-	return g.getters[node.Name], true
+	return g
 }
 
 func (g *GrpcPeer) StartService() {
 	var (
-		done    = make(chan error)
+		done    = make(chan error, 1)
 		success = make(chan struct{})
 	)
+	g.server = grpc.NewServer(grpc.UnaryInterceptor(unaryMetrics), g.option.grpcServerOption())
+	pb.RegisterPeerServer(g.server, g)
 	go func() {
 		listen, err := net.Listen("tcp", g.self)
 		if err != nil {
 			panic(fmt.Errorf("failed to listen %s, err: %v", g.self, err))
 		}
-		svr := grpc.NewServer()
-		pb.RegisterPeerServer(svr, g)
-		done <- svr.Serve(listen)
+		done <- g.server.Serve(listen)
 	}()
 	go func() {
+		conn, err := grpc.Dial(g.self, g.option.grpcDialOptions()...)
+		if err != nil {
+			panic(err)
+		}
+		defer conn.Close()
 		for {
-			conn, err := grpc.Dial(g.self, grpc.WithInsecure())
-			if err != nil {
-				panic(err)
-			}
-			_, err = pb.NewPeerClient(conn).Hello(context.TODO(), &pb.HelloRequest{})
+			_, err = pb.NewPeerClient(conn).Hello(context.TODO(), &pb.HelloRequest{}, grpc.WaitForReady(true))
 			if err == nil {
 				success <- struct{}{}
 				return
@@ -228,61 +134,168 @@ func (g *GrpcPeer) StartService() {
 	case err := <-done:
 		panic(err)
 	case <-success:
-		// 进行服务注册
-		g.Register(serviceTarget, 5)
-		// 进行服务发现
-		g.Discovery(serviceTarget)
+		// 进行服务注册与发现
+		if err := g.join(context.TODO()); err != nil {
+			panic(err)
+		}
 		log.Println("start grpc server", g.self)
 	}
 }
 
+// ServeAdmin 在独立的地址上启动管理接口, 提供 /metrics, /members 与 /weight
+func (g *GrpcPeer) ServeAdmin(addr string) {
+	g.admin = &http.Server{Addr: addr, Handler: g.adminHandler()}
+	go func() {
+		if err := g.admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println("admin server stopped, err: ", err)
+		}
+	}()
+}
+
+// Shutdown 注销自身后等待处理中的请求完成, ctx 结束时强制关闭
+func (g *GrpcPeer) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := g.leave(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if g.server != nil {
+		stopped := make(chan struct{})
+		go func() {
+			g.server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			g.server.Stop()
+			errs = append(errs, fmt.Errorf("failed to drain grpc server, err: %w", ctx.Err()))
+		}
+	}
+	if g.admin != nil {
+		if err := g.admin.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown admin server, err: %w", err))
+		}
+	}
+	if err := g.close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// GrpcGetter 持有到 peer 的长连接, 请求在连接池中轮询
 type GrpcGetter struct {
-	addr string
-	name string
+	addr  string
+	name  string
+	conns []*grpc.ClientConn
+	next  atomic.Uint32
 }
 
-func NewGrpcGetter(addr string, name string) *GrpcGetter {
-	return &GrpcGetter{
+// NewGrpcGetter 创建到 addr 的 size 个连接, 连接在后台建立并自动重连
+func NewGrpcGetter(name string, addr string, size int, opts ...grpc.DialOption) (*GrpcGetter, error) {
+	if size <= 0 {
+		size = 1
+	}
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	g := &GrpcGetter{
 		addr: addr,
 		name: name,
 	}
+	for i := 0; i < size; i++ {
+		conn, err := grpc.Dial(addr, opts...)
+		if err != nil {
+			g.Close()
+			return nil, fmt.Errorf("failed to dial %s, err: %w", addr, err)
+		}
+		g.conns = append(g.conns, conn)
+	}
+	return g, nil
 }
 
-func (g *GrpcGetter) Get(group string, key string) ([]byte, error) {
-	conn, err := grpc.Dial(g.addr, grpc.WithInsecure())
+func (g *GrpcGetter) client() pb.PeerClient {
+	conn := g.conns[g.next.Add(1)%uint32(len(g.conns))]
+	return pb.NewPeerClient(conn)
+}
+
+func (g *GrpcGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	response, err := g.client().Get(ctx, &pb.GetRequest{
+		Group: group,
+		Key:   key,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, notFound(key)
+	}
 	if err != nil {
 		return nil, err
 	}
-	client := pb.NewPeerClient(conn)
-	response, err := client.Get(context.TODO(), &pb.GetRequest{
+	return response.GetValue(), nil
+}
+
+func (g *GrpcGetter) GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	response, err := g.client().GetMany(ctx, &pb.GetManyRequest{
 		Group: group,
-		Key:   key,
+		Keys:  keys,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return response.GetValue(), nil
+	return getManyResult(keys, response)
 }
 
-func (g *GrpcGetter) Remove(group string, key string) error {
+func (g *GrpcGetter) Remove(ctx context.Context, group string, key string) error {
 	var (
 		req = &pb.DelRequest{
 			Group: group,
 			Key:   key,
 		}
 	)
-	conn, err := grpc.Dial(g.addr)
+	_, err := g.client().Del(ctx, req)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to send grpc request, err: %w", err)
 	}
-	client := pb.NewPeerClient(conn)
-	_, err = client.Del(context.TODO(), req)
+	return nil
+}
+
+func (g *GrpcGetter) Set(ctx context.Context, group string, key string, value []byte, expire time.Duration) error {
+	return g.set(ctx, &pb.SetRequest{
+		Group:  group,
+		Key:    key,
+		Value:  value,
+		Expire: int64(expire),
+	})
+}
+
+func (g *GrpcGetter) Handoff(ctx context.Context, group string, key string, value []byte, soft, expire time.Duration) error {
+	return g.set(ctx, &pb.SetRequest{
+		Group:  group,
+		Key:    key,
+		Value:  value,
+		Expire: int64(expire),
+		Soft:   int64(soft),
+		Loaded: true,
+	})
+}
+
+func (g *GrpcGetter) set(ctx context.Context, req *pb.SetRequest) error {
+	_, err := g.client().Set(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to send grpc request, err: %v", err)
+		return fmt.Errorf("failed to send grpc request, err: %w", err)
 	}
 	return nil
 }
 
+// Close 关闭所有连接
+func (g *GrpcGetter) Close() error {
+	var errs []error
+	for _, conn := range g.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (g *GrpcGetter) Name() string {
 	return g.name
 }
@@ -1,12 +1,107 @@
 package goCache
 
-import "testing"
+import (
+	"context"
+	"goCache/goCache/registry"
+	"goCache/pb"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+	"time"
+)
 
 func TestGrpcPeer_StartService(t *testing.T) {
 	var (
-		etcdAddr = "http://162.14.115.114:2379"
-		addr     = "localhost:8000"
+		addr = "localhost:8013"
 	)
-	peer := NewGrpcPeer(addr, etcdAddr)
+	peer := NewGrpcPeerWithOptions(addr, nil, WithPeerOptionsRegistry(registry.NewStatic()))
 	peer.StartService()
 }
+
+// TestGrpcGetter 多次请求复用连接, Close 后请求失败
+func TestGrpcGetter(t *testing.T) {
+	NewGroup("grpc-getter", GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	option := DefaultPeerOption()
+	svr := grpc.NewServer(option.grpcServerOption())
+	pb.RegisterPeerServer(svr, &GrpcPeer{})
+	go svr.Serve(listen)
+	defer svr.Stop()
+
+	option = DefaultPeerOption()
+	WithPeerOptionsPoolSize(2)(&option)
+	WithPeerOptionsKeepalive(time.Second, time.Second)(&option)
+	getter, err := NewGrpcGetter("test", listen.Addr().String(), option.poolSize, option.grpcDialOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(getter.conns) != 2 {
+		t.Fatalf("expected 2 conns, got %d", len(getter.conns))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		value, err := getter.Get(ctx, "grpc-getter", "key")
+		if err != nil || string(value) != "key" {
+			t.Fatalf("unexpected value %q, err: %v", value, err)
+		}
+	}
+
+	if err := getter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getter.Get(ctx, "grpc-getter", "key"); err == nil {
+		t.Fatal("expected error after Close")
+	}
+}
+
+// TestGrpcPeer_Shutdown 关闭时注销自身, 并等待处理中的请求完成
+func TestGrpcPeer_Shutdown(t *testing.T) {
+	var (
+		addr    = "localhost:8014"
+		started = make(chan struct{})
+	)
+	NewGroup("shutdown", GetterFunc(func(key string) ([]byte, error) {
+		close(started)
+		time.Sleep(time.Millisecond * 100)
+		return []byte(key), nil
+	}))
+	reg := &memRegistry{events: make(chan registry.Event)}
+	peer := NewGrpcPeerWithOptions(addr, nil, WithPeerOptionsRegistry(reg))
+	peer.StartService()
+
+	getter, err := NewGrpcGetter("test", addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer getter.Close()
+	result := make(chan error)
+	go func() {
+		_, err := getter.Get(context.Background(), "shutdown", "key")
+		result <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = peer.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed, err: %v", err)
+	}
+	if err = <-result; err != nil {
+		t.Fatalf("in-flight request failed, err: %v", err)
+	}
+	if reg.self != nil {
+		t.Fatal("expected peer to deregister")
+	}
+	// 端口已释放
+	listen, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("expected port to be released, err: %v", err)
+	}
+	listen.Close()
+}
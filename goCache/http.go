@@ -2,10 +2,8 @@ package goCache
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"go.etcd.io/etcd/api/v3/mvccpb"
-	clientv3 "go.etcd.io/etcd/client/v3"
-	"goCache/goCache/consistent"
 	"goCache/goCache/utls"
 	"goCache/pb"
 	"google.golang.org/protobuf/proto"
@@ -14,56 +12,46 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"sync"
 	"time"
 )
 
 const (
-	serviceTarget = "cache_service_prefix"
+	getManyPath = "/_getmany"
 )
 
 func NewHTTPPool(addr string, endpoints ...string) *HTTPPool {
-	cli1, err := clientv3.New(clientv3.Config{
-		Endpoints: endpoints,
-	})
-	if err != nil {
-		panic(fmt.Errorf("failed to new etcd client, err: %v", err))
-	}
-	cli2, err := clientv3.New(clientv3.Config{
-		Endpoints: endpoints,
-	})
-	if err != nil {
-		panic(fmt.Errorf("failed to new etcd client, err: %v", err))
+	return NewHTTPPoolWithOptions(addr, endpoints)
+}
+
+// NewHTTPPoolWithOptions 创建 HTTPPool, 未指定注册中心时使用 endpoints 对应的 etcd
+func NewHTTPPoolWithOptions(addr string, endpoints []string, options ...PeerOptionFunc) *HTTPPool {
+	option := DefaultPeerOption()
+	for _, op := range options {
+		op(&option)
 	}
+	option.initRegistry(endpoints)
 	return &HTTPPool{
-		self:           addr,
-		weight:         1,
-		registerCli:    cli1,
-		discoveryCli:   cli2,
-		consistentHash: consistent.New(0, nil),
-		getters:        make(map[string]PeerGetter),
+		cluster: newCluster(addr, option, func(name string, addr string) (PeerGetter, error) {
+			return NewHTTPGetter(name, addr), nil
+		}),
 	}
 }
 
 type HTTPPool struct {
-	registerCli    *clientv3.Client // 注册中心Client
-	discoveryCli   *clientv3.Client
-	leaseRespChan  <-chan *clientv3.LeaseKeepAliveResponse
-	self           string // 自身地址
-	weight         int32  // 该节点权重
-	mu             sync.RWMutex
-	consistentHash *consistent.Consistent // 一致性hash
-	getters        map[string]PeerGetter
+	*cluster
+	server *http.Server
+	admin  *http.Server
 }
 
 func (H *HTTPPool) StartService() {
 	var (
-		done    = make(chan error)
+		done    = make(chan error, 1)
 		success = make(chan struct{})
 	)
+	H.server = &http.Server{Addr: H.self[7:], Handler: H}
 	// 启动http服务
 	go func() {
-		done <- http.ListenAndServe(H.self[7:], H)
+		done <- H.server.ListenAndServe()
 	}()
 	// 检测http是否启动成功
 	go func() {
@@ -75,116 +63,60 @@ func (H *HTTPPool) StartService() {
 	case err := <-done:
 		panic(err)
 	case <-success:
-		// 进行服务注册
-		H.Register(serviceTarget, 5)
-		// 进行服务发现
-		H.Discovery(serviceTarget)
-	}
-}
-
-func (H *HTTPPool) Register(prefix string, leaseExpire int64) {
-	// 创建租约
-	lease, err := H.registerCli.Grant(context.TODO(), leaseExpire)
-	if err != nil {
-		panic(fmt.Errorf("failed grant lease, err: %v", err))
-	}
-
-	// 设置租约不过期
-	leaseKeepaliveResp, err := H.registerCli.KeepAlive(context.TODO(), lease.ID)
-	if err != nil {
-		panic(err)
-	}
-	H.leaseRespChan = leaseKeepaliveResp
-	// 进行注册
-	key := fmt.Sprintf("%s-%d", prefix, lease.ID)
-	value, err := proto.Marshal(&pb.ServiceNode{
-		Name:   H.self,
-		Addr:   H.self,
-		Weight: H.weight,
-	})
-	if err != nil {
-		panic(fmt.Errorf("failed to marshal ServiceNode, err: %v", err))
-	}
-	_, err = H.registerCli.Put(context.TODO(), key, string(value), clientv3.WithLease(lease.ID))
-	if err != nil {
-		panic(fmt.Errorf("register service to etcd failed, err: %v", err))
+		// 进行服务注册与发现
+		if err := H.join(context.TODO()); err != nil {
+			panic(err)
+		}
 	}
-	go H.ListenLeaseResp()
 }
 
-func (H *HTTPPool) ListenLeaseResp() {
-	for _ = range H.leaseRespChan {
-		//log.Println("租约续租成功", resp)
-	}
-	log.Println("关闭租约")
+// ServeAdmin 在独立的地址上启动管理接口, 提供 /metrics, /members 与 /weight
+// 数据端口只提供只读的 /metrics 与 /members
+func (H *HTTPPool) ServeAdmin(addr string) {
+	H.admin = &http.Server{Addr: addr, Handler: H.adminHandler()}
+	go func() {
+		if err := H.admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println("admin server stopped, err: ", err)
+		}
+	}()
 }
 
-func (H *HTTPPool) Discovery(prefix string) {
-
-	// 初始获取服务节点
-	resp, err := H.discoveryCli.Get(context.TODO(), prefix, clientv3.WithPrefix())
-	if err != nil {
-		panic(err)
-	}
-	for _, kv := range resp.Kvs {
-		H.SetService(string(kv.Key), string(kv.Value))
+// Shutdown 注销自身后等待处理中的请求完成, ctx 结束时强制关闭
+func (H *HTTPPool) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := H.leave(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if H.server != nil {
+		if err := H.server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown http server, err: %w", err))
+			H.server.Close()
+		}
 	}
-
-	// 开启监听注册中心
-	go H.watch(H.discoveryCli, prefix)
-}
-
-func (H *HTTPPool) watch(cli *clientv3.Client, prefix string) {
-	watchCh := cli.Watch(context.TODO(), prefix, clientv3.WithPrefix())
-	for resp := range watchCh {
-		for _, ev := range resp.Events {
-			switch ev.Type {
-			case mvccpb.PUT:
-				H.SetService(string(ev.Kv.Key), string(ev.Kv.Value))
-			case mvccpb.DELETE:
-				H.DelService(string(ev.Kv.Key))
-			}
+	if H.admin != nil {
+		if err := H.admin.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown admin server, err: %w", err))
 		}
 	}
-}
-
-func (H *HTTPPool) SetService(key string, value string) {
-	H.mu.Lock()
-	defer H.mu.Unlock()
-
-	// 一致性hash节点添加
-	t := &pb.ServiceNode{}
-	err := proto.Unmarshal([]byte(value), t)
-	if err != nil {
-		panic(err)
+	if err := H.close(); err != nil {
+		errs = append(errs, err)
 	}
-	H.consistentHash.AddNode(consistent.Node{
-		Name:   t.GetName(),
-		Addr:   t.GetAddr(),
-		Weight: t.GetWeight(),
-	})
-	// PeerGetter 添加
-	H.getters[t.GetName()] = NewHTTPGetter(t.GetName(), t.GetAddr())
-}
-
-func (H *HTTPPool) DelService(key string) {
-	H.mu.Lock()
-	defer H.mu.Unlock()
-	H.consistentHash.DelNode(key)
-	delete(H.getters, key)
-}
-
-func (H *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
-	H.mu.RLock()
-	defer H.mu.RUnlock()
-	node, err := H.consistentHash.GetNode(key)
-	if err != nil || node.Addr == H.self {
-		return nil, false
-	}
-	return H.getters[node.Name], true
+	return errors.Join(errs...)
 }
 
 func (H *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == metricsPath {
+		MetricsHandler().ServeHTTP(w, r)
+		return
+	}
+	if r.URL.Path == membersPath {
+		H.membersHandler(w, r)
+		return
+	}
+	if r.URL.Path == getManyPath {
+		H.GetManyHandler(w, r)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		H.GetHandler(w, r)
@@ -225,7 +157,15 @@ func (H *HTTPPool) GetHandler(w http.ResponseWriter, r *http.Request) {
 		w.Write(data)
 		return
 	}
-	value, err := cache.Get(in.GetKey())
+	value, err := cache.getLocally(r.Context(), in.GetKey())
+	if errors.Is(err, ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		resp.Msg = err.Error()
+		resp.NotFound = true
+		data, _ := proto.Marshal(&resp)
+		w.Write(data)
+		return
+	}
 	if err != nil {
 		log.Println(err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
@@ -244,36 +184,88 @@ func (H *HTTPPool) GetHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
-func (H *HTTPPool) DeleteHandler(w http.ResponseWriter, r *http.Request) {
-	paths := strings.SplitN(r.URL.Path, "/", 2)
-	group, key := paths[0], paths[1]
-	cache, exist := GetGroup(group)
+func (H *HTTPPool) GetManyHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		resp pb.GetManyResponse
+		in   pb.GetManyRequest
+	)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		resp.Msg = "failed to read request body"
+		data, _ := proto.Marshal(&resp)
+		w.Write(data)
+		return
+	}
+	if err = proto.Unmarshal(data, &in); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		resp.Msg = "failed to unmarshal request body"
+		data, _ := proto.Marshal(&resp)
+		w.Write(data)
+		return
+	}
+	cache, exist := GetGroup(in.GetGroup())
 	if !exist {
 		w.WriteHeader(http.StatusNotFound)
+		resp.Msg = fmt.Sprintf("failed to get group, group: %s", in.Group)
+		data, _ := proto.Marshal(&resp)
+		w.Write(data)
 		return
 	}
-	if err := cache.Remove(key); err != nil {
+	values, notFound, err := cache.getManyLocally(r.Context(), in.GetKeys())
+	if err != nil {
+		log.Println(err.Error())
+	}
+	body, err := proto.Marshal(getManyResponse(in.GetKeys(), values, notFound, err))
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
+	w.Write(body)
 }
 
-func (H *HTTPPool) PostHandler(w http.ResponseWriter, r *http.Request) {
-	paths := strings.SplitN(r.URL.Path, "/", 1)
-	group := paths[0]
+func (H *HTTPPool) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	// 路径为 /group/key
+	paths := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(paths) != 2 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	group, key := paths[0], paths[1]
 	cache, exist := GetGroup(group)
 	if !exist {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	if err := cache.removeLocally(key); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (H *HTTPPool) PostHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	req := &pb.SetRequest{}
 	if err = proto.Unmarshal(body, req); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	cache.Set(req.Key, req.Value, time.Duration(req.Expire))
+	cache, exist := GetGroup(req.GetGroup())
+	if !exist {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if req.Loaded {
+		cache.setHandoff(req.Key, req.Value, time.Duration(req.Soft), time.Duration(req.Expire))
+	} else {
+		cache.setLocally(req.Key, req.Value, time.Duration(req.Expire))
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -299,7 +291,7 @@ func (H HTTPGetter) Name() string {
 	return H.name
 }
 
-func (H HTTPGetter) Get(group string, key string) ([]byte, error) {
+func (H HTTPGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
 	data, err := proto.Marshal(&pb.GetRequest{
 		Group: group,
 		Key:   key,
@@ -307,9 +299,19 @@ func (H HTTPGetter) Get(group string, key string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := utls.Get(H.baseURl, data)
+	resp, err := utls.Get(ctx, H.baseURl, data)
+	// 只有响应标记 key 不存在时才视为 ErrNotFound, group 不存在等 404 仍为普通错误
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respData := pb.GetResponse{}
+		if proto.Unmarshal(body, &respData) == nil && respData.NotFound {
+			return nil, notFound(key)
+		}
+		return nil, fmt.Errorf("failed to get from peer, msg: %s, err: %w", respData.Msg, err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request, err: %v", err)
+		return nil, fmt.Errorf("failed to send request, err: %w", err)
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
@@ -323,34 +325,76 @@ func (H HTTPGetter) Get(group string, key string) ([]byte, error) {
 	return respData.Value, nil
 }
 
-func (H HTTPGetter) Remove(namespace string, key string) error {
-	u, err := url.JoinPath(H.baseURl, url.QueryEscape(namespace), url.QueryEscape(key))
+func (H HTTPGetter) GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	data, err := proto.Marshal(&pb.GetManyRequest{
+		Group: group,
+		Keys:  keys,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to splicing url, err: %v", err)
+		return nil, err
 	}
-	if _, err = utls.Delete(u); err != nil {
-		return err
+	u, err := url.JoinPath(H.baseURl, getManyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to splicing url, err: %v", err)
 	}
-	return nil
+	resp, err := utls.Post(ctx, u, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request, err: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body, err: %v", err)
+	}
+	respData := pb.GetManyResponse{}
+	if err = proto.Unmarshal(body, &respData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body, err: %v", err)
+	}
+	return getManyResult(keys, &respData)
 }
 
-func (H HTTPGetter) Set(group string, key string, value []byte, expire time.Duration) error {
-	u, err := url.JoinPath(H.baseURl, url.QueryEscape(group))
+func (H HTTPGetter) Remove(ctx context.Context, namespace string, key string) error {
+	u, err := url.JoinPath(H.baseURl, url.PathEscape(namespace), url.PathEscape(key))
 	if err != nil {
 		return fmt.Errorf("failed to splicing url, err: %v", err)
 	}
-	req := &pb.SetRequest{
+	if _, err = utls.Delete(ctx, u); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (H HTTPGetter) Set(ctx context.Context, group string, key string, value []byte, expire time.Duration) error {
+	return H.set(ctx, &pb.SetRequest{
 		Group:  group,
 		Key:    key,
 		Value:  value,
 		Expire: int64(expire),
+	})
+}
+
+func (H HTTPGetter) Handoff(ctx context.Context, group string, key string, value []byte, soft, expire time.Duration) error {
+	return H.set(ctx, &pb.SetRequest{
+		Group:  group,
+		Key:    key,
+		Value:  value,
+		Expire: int64(expire),
+		Soft:   int64(soft),
+		Loaded: true,
+	})
+}
+
+func (H HTTPGetter) set(ctx context.Context, req *pb.SetRequest) error {
+	u, err := url.JoinPath(H.baseURl, url.QueryEscape(req.Group))
+	if err != nil {
+		return fmt.Errorf("failed to splicing url, err: %v", err)
 	}
 	body, err := proto.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal requset body, err: %v", err)
 	}
 
-	if _, err = utls.Post(u, body); err != nil {
+	if _, err = utls.Post(ctx, u, body); err != nil {
 		return err
 	}
 	return nil
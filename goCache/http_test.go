@@ -1,17 +1,21 @@
 package goCache
 
 import (
+	"context"
+	"fmt"
+	"goCache/goCache/registry"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestHTTPPool(t *testing.T) {
 	var (
-		etcdAddr = "http://162.14.115.114:2379"
-		addr     = "http://localhost:8000"
+		addr = "http://localhost:8012"
 	)
-	pool := NewHTTPPool(addr, etcdAddr)
+	pool := NewHTTPPoolWithOptions(addr, nil, WithPeerOptionsRegistry(registry.NewStatic()))
 	pool.StartService()
 
 	getUrl, err := url.JoinPath(addr, "test-group", "test-key")
@@ -19,5 +23,30 @@ func TestHTTPPool(t *testing.T) {
 		t.Fatalf("fortmat get url error, err: %v", err)
 	}
 	resp, err := http.Get(getUrl)
+	if err != nil {
+		t.Fatalf("failed to send request, err: %v", err)
+	}
 	t.Log(resp.StatusCode, resp.Status)
 }
+
+func TestHTTPGetter_SetRemove(t *testing.T) {
+	group := NewGroup("http-set", GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("not found")
+	}))
+	srv := httptest.NewServer(&HTTPPool{})
+	defer srv.Close()
+	getter := NewHTTPGetter("test", srv.URL)
+
+	if err := getter.Set(context.Background(), "http-set", "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set failed, err: %v", err)
+	}
+	if v, ok := group.mainCache.Get("key"); !ok || v.(ByteView).String() != "value" {
+		t.Fatal("expected key to be set")
+	}
+	if err := getter.Remove(context.Background(), "http-set", "key"); err != nil {
+		t.Fatalf("Remove failed, err: %v", err)
+	}
+	if _, ok := group.mainCache.Get("key"); ok {
+		t.Fatal("expected key to be removed")
+	}
+}
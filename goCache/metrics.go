@@ -0,0 +1,118 @@
+package goCache
+
+import (
+	"context"
+	"goCache/goCache/metrics"
+	"net/http"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	metricsPath = "/metrics"
+)
+
+var (
+	getterLatency = metrics.NewHistogramVec("gocache_getter_duration_seconds",
+		"Latency of Getter.Get calls.", nil, "group")
+	peerLatency = metrics.NewHistogramVec("gocache_peer_get_duration_seconds",
+		"Latency of PeerGetter.Get calls.", nil, "group", "peer")
+	peerRequests = metrics.NewCounterVec("gocache_peer_requests_total",
+		"Requests sent to peers.", "group", "peer", "op", "result")
+	grpcLatency = metrics.NewHistogramVec("gocache_grpc_server_handling_seconds",
+		"Latency of gRPC server handlers.", nil, "method", "code")
+)
+
+func init() {
+	metrics.DefaultRegistry.Register(metrics.CollectorFunc(collectGroups))
+	metrics.DefaultRegistry.Register(getterLatency)
+	metrics.DefaultRegistry.Register(peerLatency)
+	metrics.DefaultRegistry.Register(peerRequests)
+	metrics.DefaultRegistry.Register(grpcLatency)
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出所有指标
+func MetricsHandler() http.Handler {
+	return metrics.DefaultRegistry.Handler()
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// unaryMetrics 记录 gRPC 服务端处理延迟
+func unaryMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	grpcLatency.Since(start, info.FullMethod, status.Code(err).String())
+	return resp, err
+}
+
+type groupMetric struct {
+	name  string
+	help  string
+	typ   string
+	value func(g *Group, stats GroupStats) float64
+}
+
+var groupMetrics = []groupMetric{
+	{"gocache_group_gets_total", "Group.Get calls.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.Gets) }},
+	{"gocache_group_main_cache_hits_total", "mainCache hits.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.MainCacheHits) }},
+	{"gocache_group_hot_cache_hits_total", "hotCache hits.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.HotCacheHits) }},
+	{"gocache_group_local_loads_total", "Loads through the Getter.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.LocalLoads) }},
+	{"gocache_group_peer_loads_total", "Loads from peers.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.PeerLoads) }},
+	{"gocache_group_peer_errors_total", "Failed loads from peers.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.PeerErrors) }},
+	{"gocache_group_loader_errors_total", "Failed loads through the Getter.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.LoaderErrors) }},
+	{"gocache_group_stale_hits_total", "Stale entries served while revalidating.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.StaleHits) }},
+	{"gocache_group_revalidations_total", "Background refreshes of stale entries.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.Revalidations) }},
+	{"gocache_group_refresh_ahead_total", "Hot keys reloaded before expiry.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.RefreshAheads) }},
+	{"gocache_group_negative_hits_total", "Lookups answered by the negative cache.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.NegativeHits) }},
+	{"gocache_singleflight_calls_total", "Loads executed by singleflight.", "counter", func(g *Group, s GroupStats) float64 { return float64(g.loader.Stats().Calls) }},
+	{"gocache_singleflight_dups_total", "Loads deduplicated by singleflight.", "counter", func(g *Group, s GroupStats) float64 { return float64(s.LoadsDeduped) }},
+}
+
+// collectGroups 输出所有 group 及其缓存的指标
+func collectGroups(w *metrics.Writer) {
+	mu.RLock()
+	all := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		all = append(all, g)
+	}
+	mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].name < all[j].name
+	})
+
+	stats := make([]GroupStats, len(all))
+	for i, g := range all {
+		stats[i] = g.Stats()
+	}
+	for _, m := range groupMetrics {
+		w.Header(m.name, m.help, m.typ)
+		for i, g := range all {
+			w.Sample(m.name, m.value(g, stats[i]), "group", g.name)
+		}
+	}
+
+	w.Header("gocache_cache_bytes", "Bytes used by the cache.", "gauge")
+	for i, g := range all {
+		w.Sample("gocache_cache_bytes", float64(stats[i].MainCache.Bytes), "group", g.name, "cache", "main")
+		w.Sample("gocache_cache_bytes", float64(stats[i].HotCache.Bytes), "group", g.name, "cache", "hot")
+	}
+	w.Header("gocache_cache_items", "Entries in the cache.", "gauge")
+	for i, g := range all {
+		w.Sample("gocache_cache_items", float64(stats[i].MainCache.Items), "group", g.name, "cache", "main")
+		w.Sample("gocache_cache_items", float64(stats[i].HotCache.Items), "group", g.name, "cache", "hot")
+	}
+	w.Header("gocache_cache_evictions_total", "Entries evicted for capacity or expiry.", "counter")
+	for i, g := range all {
+		w.Sample("gocache_cache_evictions_total", float64(stats[i].MainCache.Evictions), "group", g.name, "cache", "main")
+		w.Sample("gocache_cache_evictions_total", float64(stats[i].HotCache.Evictions), "group", g.name, "cache", "hot")
+	}
+}
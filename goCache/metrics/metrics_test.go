@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec("test_requests_total", "Total requests.", "peer", "result")
+	h := NewHistogramVec("test_duration_seconds", "Request latency.", []float64{0.1, 1}, "peer")
+	r.Register(c)
+	r.Register(h)
+	r.Register(CollectorFunc(func(w *Writer) {
+		w.Header("test_items", "Items.", "gauge")
+		w.Sample("test_items", 3, "group", `a"b`)
+	}))
+
+	c.Inc("localhost:8001", "ok")
+	c.Inc("localhost:8001", "ok")
+	c.Inc("localhost:8001", "error")
+	h.Observe(0.05, "localhost:8001")
+	h.Observe(0.5, "localhost:8001")
+	h.Observe(5, "localhost:8001")
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	r.WriteTo(w)
+	w.Flush()
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{peer="localhost:8001",result="ok"} 2` + "\n",
+		`test_requests_total{peer="localhost:8001",result="error"} 1` + "\n",
+		"# TYPE test_duration_seconds histogram\n",
+		`test_duration_seconds_bucket{peer="localhost:8001",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{peer="localhost:8001",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{peer="localhost:8001",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{peer="localhost:8001"} 5.55` + "\n",
+		`test_duration_seconds_count{peer="localhost:8001"} 3` + "\n",
+		`test_items{group="a\"b"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
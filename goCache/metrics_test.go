@@ -0,0 +1,31 @@
+package goCache
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	group := NewGroup("metrics", GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	group.Get("Tom")
+	group.Get("Tom")
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", metricsPath, nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`gocache_group_gets_total{group="metrics"} 2`,
+		`gocache_group_main_cache_hits_total{group="metrics"} 1`,
+		`gocache_cache_items{group="metrics",cache="main"} 1`,
+		`gocache_getter_duration_seconds_count{group="metrics"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package goCache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound 数据不存在, Getter 返回 (或包装) 该错误时结果会被负缓存, 跨节点传递后仍可通过 errors.Is 判断
+var ErrNotFound = errors.New("not found")
+
+// notFound 包装 ErrNotFound 并附带 key
+func notFound(key string) error {
+	return fmt.Errorf("key %s: %w", key, ErrNotFound)
+}
+
+// lookupNegative key 是否在负缓存中
+func (c *Group) lookupNegative(key string) bool {
+	if c.negativeCache == nil {
+		return false
+	}
+	if _, ok := c.negativeCache.Get(key); !ok {
+		return false
+	}
+	c.stats.negativeHits.Add(1)
+	return true
+}
+
+// setNegative 记录 key 不存在, 同时删除本地缓存的旧数据
+func (c *Group) setNegative(key string) {
+	if c.negativeCache == nil {
+		return
+	}
+	c.mainCache.Delete(key)
+	c.hotCache.Delete(key)
+	c.negativeCache.Set(key, ByteView{}, c.negativeTTL)
+}
+
+// deleteNegative key 被写入后不再视为不存在
+func (c *Group) deleteNegative(key string) {
+	if c.negativeCache != nil {
+		c.negativeCache.Delete(key)
+	}
+}
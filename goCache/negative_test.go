@@ -0,0 +1,182 @@
+package goCache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCache/goCache/registry"
+	"goCache/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Negative(t *testing.T) {
+	var loads atomic.Int32
+	group := NewGroup("negative", GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		if key == "Tom" {
+			return []byte("630"), nil
+		}
+		return nil, fmt.Errorf("unknown key %s: %w", key, ErrNotFound)
+	}), WithCacheOptionsNegative(time.Millisecond*50, 1<<10))
+
+	for i := 0; i < 3; i++ {
+		if _, err := group.Get("unknown"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected 1 load, got %d", n)
+	}
+	if hits := group.Stats().NegativeHits; hits != 2 {
+		t.Fatalf("expected 2 negative hits, got %d", hits)
+	}
+	if errs := group.Stats().LoaderErrors; errs != 0 {
+		t.Fatalf("expected not found not to count as loader error, got %d", errs)
+	}
+
+	// GetMany 不返回不存在的 key, 也不视为错误
+	values, err := group.GetMany(context.Background(), []string{"Tom", "unknown", "missing"})
+	if err != nil || len(values) != 1 || values["Tom"].String() != "630" {
+		t.Fatalf("expected only Tom, got %v %v", values, err)
+	}
+	if n := loads.Load(); n != 3 {
+		t.Fatalf("expected 3 loads, got %d", n)
+	}
+
+	// 写入后不再视为不存在
+	group.Set("unknown", []byte("1"), time.Minute)
+	if v, err := group.Get("unknown"); err != nil || v.String() != "1" {
+		t.Fatalf("expected 1, got %v %v", v, err)
+	}
+
+	// 过期后重新加载
+	time.Sleep(time.Millisecond * 60)
+	if _, err := group.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n := loads.Load(); n != 4 {
+		t.Fatalf("expected 4 loads, got %d", n)
+	}
+}
+
+// groupGetter 将请求转发到另一个 group, 使同一进程中可以模拟所有者节点
+type groupGetter struct {
+	HTTPGetter
+	group string
+	gets  atomic.Int32
+}
+
+func (g *groupGetter) Get(ctx context.Context, group string, key string) ([]byte, error) {
+	g.gets.Add(1)
+	return g.HTTPGetter.Get(ctx, g.group, key)
+}
+
+func (g *groupGetter) GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	return g.HTTPGetter.GetMany(ctx, g.group, keys)
+}
+
+func TestGroup_NegativePeer(t *testing.T) {
+	var loads atomic.Int32
+	NewGroup("negative-owner", GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		return nil, ErrNotFound
+	}), WithCacheOptionsNegative(time.Minute, 1<<10))
+	server := httptest.NewServer(&HTTPPool{})
+	defer server.Close()
+
+	// 所有者返回的 ErrNotFound 在 HTTP 与 gRPC 上都可以被识别
+	if _, err := NewHTTPGetter("owner", server.URL).Get(context.Background(), "negative-owner", "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound over http, got %v", err)
+	}
+	// group 不存在时不视为 key 不存在
+	if _, err := NewHTTPGetter("owner", server.URL).Get(context.Background(), "negative-unknown", "key"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected unknown group not to be ErrNotFound, got %v", err)
+	}
+	if _, err := (&GrpcPeer{}).Get(context.Background(), &pb.GetRequest{Group: "negative-owner", Key: "key"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected grpc NotFound, got %v", err)
+	}
+
+	self := "http://localhost:8071"
+	owner := &groupGetter{HTTPGetter: *NewHTTPGetter("http://localhost:8072", server.URL), group: "negative-owner"}
+	peer := NewHTTPPoolWithOptions(self, nil, WithPeerOptionsRegistry(registry.NewStatic()))
+	peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+		return owner, nil
+	}
+	peer.SetService(&pb.ServiceNode{Name: self, Addr: self, Weight: 1})
+	peer.SetService(&pb.ServiceNode{Name: owner.name, Addr: owner.name, Weight: 1})
+
+	group := NewGroup("negative-remote", GetterFunc(func(key string) ([]byte, error) {
+		t.Errorf("key %s should be loaded by the owner", key)
+		return nil, nil
+	}), WithCacheOptionsNegative(time.Minute, 1<<10))
+	group.RegisterPeer(peer)
+
+	var key string
+	for i := 0; key == ""; i++ {
+		if _, ok := peer.PickPeer(fmt.Sprintf("key%d", i)); ok {
+			key = fmt.Sprintf("key%d", i)
+		}
+	}
+	// 非所有者节点缓存所有者返回的不存在结果, 不再请求所有者
+	for i := 0; i < 3; i++ {
+		if _, err := group.Get(key); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if n := owner.gets.Load(); n != 1 {
+		t.Fatalf("expected 1 request to the owner, got %d", n)
+	}
+	if n := loads.Load(); n != 2 {
+		t.Fatalf("expected 2 loads on the owner, got %d", n)
+	}
+	if errs := group.Stats().PeerErrors; errs != 0 {
+		t.Fatalf("expected not found not to count as peer error, got %d", errs)
+	}
+}
+
+func TestGroup_NegativePeerMany(t *testing.T) {
+	NewGroup("negative-many-owner", GetterFunc(func(key string) ([]byte, error) {
+		if key == "remote-1" {
+			return []byte("1"), nil
+		}
+		return nil, ErrNotFound
+	}), WithCacheOptionsNegative(time.Minute, 1<<10))
+	server := httptest.NewServer(&HTTPPool{})
+	defer server.Close()
+
+	// 所有者在批量响应中标记不存在的 key, 没有结果也没有标记的 key 视为加载失败
+	resp, err := (&GrpcPeer{}).GetMany(context.Background(), &pb.GetManyRequest{Group: "negative-many-owner", Keys: []string{"remote-1", "remote-2"}})
+	if err != nil || len(resp.GetNotFound()) != 1 || resp.GetNotFound()[0] != "remote-2" {
+		t.Fatalf("expected remote-2 to be marked not found, got %v %v", resp, err)
+	}
+	var batchErr *BatchError
+	if _, err = getManyResult([]string{"remote-1", "remote-2", "remote-3"}, resp); !errors.As(err, &batchErr) || len(batchErr.Keys) != 1 || batchErr.Keys[0] != "remote-3" {
+		t.Fatalf("expected unmarked remote-3 to fail, got %v", err)
+	}
+
+	// 非所有者节点负缓存所有者标记为不存在的 key
+	owner := &groupGetter{HTTPGetter: *NewHTTPGetter("owner", server.URL), group: "negative-many-owner"}
+	group := NewGroup("negative-many", GetterFunc(func(key string) ([]byte, error) {
+		t.Errorf("key %s should be loaded by the owner", key)
+		return nil, nil
+	}), WithCacheOptionsNegative(time.Minute, 1<<10))
+	group.RegisterPeer(&fakePeer{owner: owner})
+	values, err := group.GetMany(context.Background(), []string{"remote-1", "remote-2"})
+	if err != nil || len(values) != 1 || values["remote-1"].String() != "1" {
+		t.Fatalf("expected only remote-1, got %v %v", values, err)
+	}
+	if _, err = group.Get("remote-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if n := owner.gets.Load(); n != 0 {
+		t.Fatalf("expected no request to the owner, got %d", n)
+	}
+	if hits := group.Stats().NegativeHits; hits != 1 {
+		t.Fatalf("expected 1 negative hit, got %d", hits)
+	}
+}
@@ -1,30 +1,162 @@
 package goCache
 
-import "goCache/goCache/cache"
+import (
+	"goCache/goCache/cache"
+	"time"
+)
 
 type CacheOption struct {
-	mainCache cache.Cache
-	hotCache  cache.Cache
+	mainCache      cache.Cache
+	hotCache       cache.Cache
+	sweepInterval  time.Duration         // 过期数据清理间隔, 0 表示不主动清理
+	evictedFuncs   []cache.OnEvictedFunc // 淘汰事件订阅者
+	replicas       int                   // 副本数, 包括主节点
+	handoff        bool                  // 成员变更后将不再属于本节点的 key 交给新的所有者
+	softTTL        time.Duration         // 软过期时间, 超过后返回旧数据并在后台刷新, 0 表示不启用
+	hardTTL        time.Duration         // 硬过期时间, 超过后同步加载
+	refreshAhead   time.Duration         // 热点 key 剩余时间不超过 refreshAhead 时提前刷新, 0 表示不启用
+	refreshHits    int                   // 热点 key 每个检查周期的最少访问次数
+	refreshWorkers int                   // 提前刷新的 worker 数
+	negativeCache  cache.Cache           // 记录不存在的 key, nil 表示不启用
+	negativeTTL    time.Duration         // 不存在的 key 的缓存时间
 }
 
 type CacheOptionFunc func(option *CacheOption)
 
 func WithCacheOptionsStrategy(Strategy string, maxBytes int64, evictedFunc cache.OnEvictedFunc) func(option *CacheOption) {
 	return func(option *CacheOption) {
-		switch Strategy {
-		case "lru":
-			option.mainCache = cache.NewLRU(maxBytes, evictedFunc)
-			option.hotCache = cache.NewLRU(maxBytes/8, evictedFunc)
-		default:
-			option.mainCache = cache.NewLRU(maxBytes, evictedFunc)
-			option.hotCache = cache.NewLRU(maxBytes/8, evictedFunc)
+		option.subscribe(evictedFunc)
+		factory := strategyFactory(Strategy)
+		option.mainCache = factory(maxBytes, option.notify)
+		option.hotCache = factory(maxBytes/8, option.notify)
+	}
+}
+
+// WithCacheOptionsSharded 使用分片缓存, 每个分片使用 Strategy 指定的淘汰策略
+func WithCacheOptionsSharded(shards int, Strategy string, maxBytes int64, evictedFunc cache.OnEvictedFunc) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.subscribe(evictedFunc)
+		factory := strategyFactory(Strategy)
+		option.mainCache = cache.NewSharded(shards, maxBytes, option.notify, factory)
+		option.hotCache = cache.NewSharded(shards, maxBytes/8, option.notify, factory)
+	}
+}
+
+// WithCacheOptionsOnEvicted 订阅 mainCache 和 hotCache 的淘汰事件
+func WithCacheOptionsOnEvicted(evictedFunc cache.OnEvictedFunc) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.subscribe(evictedFunc)
+	}
+}
+
+// WithCacheOptionsJanitor 每隔 interval 主动清理过期数据
+func WithCacheOptionsJanitor(interval time.Duration) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.sweepInterval = interval
+	}
+}
+
+// WithCacheOptionsReplicas 每个 key 保存在 n 个节点上, Set 写入所有副本, 主节点不可达时 Get 读取其他副本
+func WithCacheOptionsReplicas(n int) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.replicas = n
+	}
+}
+
+// WithCacheOptionsHandoff 成员变更后将不再属于本节点的 key 写入新的所有者再删除, 否则直接删除
+func WithCacheOptionsHandoff(enable bool) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.handoff = enable
+	}
+}
+
+// WithCacheOptionsStaleWhileRevalidate 加载的数据超过 soft 后仍直接返回, 同时在后台刷新一次, 超过 hard 后才同步加载
+// hard 不大于 soft 时为 soft 的两倍; 只对本节点负责的 key 生效, 从其他节点缓存的热点副本在 soft 后过期
+func WithCacheOptionsStaleWhileRevalidate(soft, hard time.Duration) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.softTTL = soft
+		option.hardTTL = hard
+	}
+}
+
+// WithCacheOptionsRefreshAhead 每隔 ahead/2 检查一次, 期间访问不少于 minHits 次且在 ahead 内将要过期
+// (启用 stale-while-revalidate 时为软过期) 的 key 由 workers 个 worker 提前重新加载
+// ahead 至少为 10ms, minHits 不大于 0 时为 2, workers 不大于 0 时为 4
+func WithCacheOptionsRefreshAhead(ahead time.Duration, minHits int, workers int) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.refreshAhead = ahead
+		option.refreshHits = minHits
+		option.refreshWorkers = workers
+	}
+}
+
+// WithCacheOptionsNegative Getter 返回 ErrNotFound 的 key 缓存 ttl, 期间不再加载, 最多占用 maxBytes
+// 非所有者节点从所有者得到 ErrNotFound 时同样缓存, 写入后删除
+func WithCacheOptionsNegative(ttl time.Duration, maxBytes int64) func(option *CacheOption) {
+	return func(option *CacheOption) {
+		option.negativeTTL = ttl
+		option.negativeCache = cache.NewLRU(maxBytes, nil)
+	}
+}
+
+func strategyFactory(Strategy string) cache.Factory {
+	switch Strategy {
+	case "tinylfu":
+		return func(maxBytes int64, evictedFunc cache.OnEvictedFunc) cache.Cache {
+			return cache.NewTinyLFU(maxBytes, evictedFunc)
+		}
+	case "arc":
+		return func(maxBytes int64, evictedFunc cache.OnEvictedFunc) cache.Cache {
+			return cache.NewARC(maxBytes, evictedFunc)
+		}
+	default:
+		return func(maxBytes int64, evictedFunc cache.OnEvictedFunc) cache.Cache {
+			return cache.NewLRU(maxBytes, evictedFunc)
 		}
 	}
 }
 
 func DefaultCacheOption() CacheOption {
-	return CacheOption{
-		mainCache: cache.NewLRU(0, nil),
-		hotCache:  cache.NewLRU(0, nil),
+	return CacheOption{replicas: 1}
+}
+
+// init 未指定淘汰策略时使用 LRU, 副本数至少为 1
+func (o *CacheOption) init() {
+	if o.mainCache == nil {
+		o.mainCache = cache.NewLRU(0, o.notify)
+	}
+	if o.hotCache == nil {
+		o.hotCache = cache.NewLRU(0, o.notify)
+	}
+	if o.replicas < 1 {
+		o.replicas = 1
+	}
+	if o.softTTL > 0 && o.hardTTL <= o.softTTL {
+		o.hardTTL = o.softTTL * 2
+	}
+	if o.refreshAhead > 0 && o.refreshAhead < minRefreshAhead {
+		o.refreshAhead = minRefreshAhead
+	}
+	if o.refreshHits <= 0 {
+		o.refreshHits = defaultRefreshHits
+	}
+	if o.refreshWorkers <= 0 {
+		o.refreshWorkers = defaultRefreshWorkers
+	}
+	if o.negativeTTL <= 0 {
+		o.negativeCache = nil
+	}
+}
+
+func (o *CacheOption) subscribe(evictedFunc cache.OnEvictedFunc) {
+	if evictedFunc != nil {
+		o.evictedFuncs = append(o.evictedFuncs, evictedFunc)
+	}
+}
+
+// notify 将淘汰事件分发给所有订阅者
+func (o *CacheOption) notify(event cache.EvictEvent) {
+	for _, fn := range o.evictedFuncs {
+		fn(event)
 	}
 }
@@ -1,35 +1,59 @@
 package goCache
 
-import clientv3 "go.etcd.io/etcd/client/v3"
+import (
+	"context"
+	"errors"
+	"goCache/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"time"
+)
 
 type Peer interface {
-	Register
 	Discovery
 	PeerPicker
 	StartService()
+	Shutdown(ctx context.Context) error // 注销自身, 停止服务并释放连接
 }
 
 // PeerPicker 对等体选择接口
 type PeerPicker interface {
 	PickPeer(key string) (PeerGetter, bool)
+	// PickPeers 返回 key 的前 n 个不同的节点, 按优先级排列, 自身节点对应的位置为 nil
+	// 节点带有拓扑标签时尽量分散到不同的 zone, 配置了自身 zone 时本节点与同 zone 的节点优先
+	PickPeers(key string, n int) []PeerGetter
 }
 
 // PeerGetter 对等体交互发送端
 type PeerGetter interface {
-	Get(group string, key string) ([]byte, error)
-	Remove(group string, key string) error
-	Name() string // 名字
-	Addr() string // 地址
+	Get(ctx context.Context, group string, key string) ([]byte, error)
+	GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) // 批量获取, 结果中不存在的 key 表示未找到, 部分 key 加载失败时同时返回 BatchError
+	Remove(ctx context.Context, group string, key string) error
+	Set(ctx context.Context, group string, key string, value []byte, expire time.Duration) error
+	Handoff(ctx context.Context, group string, key string, value []byte, soft, expire time.Duration) error // 再平衡时转移通过 Getter 加载的数据, 保留剩余的软过期与过期时间
+	Name() string                                                                                          // 名字
+	Addr() string                                                                                          // 地址
 }
 
+// Discovery 集群成员变更, 由注册中心的事件驱动
 type Discovery interface {
-	Discovery(prefix string)                   // 服务发现
-	watch(cli *clientv3.Client, prefix string) // 对注册中心进行监听
-	SetService(key string, value string)       // 设置服务节点
-	DelService(key string)                     // 删除服务节点
+	SetService(node *pb.ServiceNode) // 设置服务节点
+	DelService(name string)          // 删除服务节点
 }
 
-type Register interface {
-	Register(prefix string, leaseExpire int64) // 注册服务
-	ListenLeaseResp()                          //
+// unreachable 判断错误是否由于 peer 不可达, 此时可以尝试其他副本
+func unreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
 }
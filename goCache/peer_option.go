@@ -0,0 +1,166 @@
+package goCache
+
+import (
+	"fmt"
+	"goCache/goCache/consistent"
+	"goCache/goCache/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"math"
+	"time"
+)
+
+const (
+	serviceTarget           = "cache_service_prefix"
+	defaultLeaseExpire      = 5
+	defaultKeepaliveTime    = time.Second * 30
+	defaultKeepaliveTimeout = time.Second * 10
+	capacityPerWeight       = 64 << 20 // 按容量计算权重时每单位权重的容量
+)
+
+type PeerOption struct {
+	registry    registry.Registry          // 注册中心
+	poolSize    int                        // 每个 peer 的 grpc 连接数
+	keepalive   keepalive.ClientParameters // 连接保活参数
+	dialOptions []grpc.DialOption          // 额外的 grpc 拨号参数
+	picker      consistent.Picker          // 节点放置算法, 为 nil 时使用虚拟节点一致性hash
+	boundedLoad float64                    // 有界负载的 epsilon, 0 表示不启用
+	zone        string                     // 自身所在的可用区
+	rack        string                     // 自身所在的机架
+	host        string                     // 自身所在的主机
+	weight      int32                      // 自身权重, 0 表示按容量计算
+	capacity    int64                      // 自身缓存容量, 随注册信息发布
+	labels      map[string]string          // 自定义标签, 随注册信息发布
+}
+
+type PeerOptionFunc func(option *PeerOption)
+
+func DefaultPeerOption() PeerOption {
+	return PeerOption{
+		poolSize: 1,
+		keepalive: keepalive.ClientParameters{
+			Time:                defaultKeepaliveTime,
+			Timeout:             defaultKeepaliveTimeout,
+			PermitWithoutStream: true,
+		},
+	}
+}
+
+// WithPeerOptionsRegistry 使用指定的注册中心进行服务注册与发现
+func WithPeerOptionsRegistry(r registry.Registry) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.registry = r
+	}
+}
+
+// WithPeerOptionsPicker 使用指定的节点放置算法, 如 consistent.NewPicker("maglev"),
+// 集群中所有节点须使用相同的算法
+func WithPeerOptionsPicker(p consistent.Picker) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.picker = p
+	}
+}
+
+// WithPeerOptionsBoundedLoad PickPeer 使用有界负载的一致性hash,
+// 节点处理中的请求数超过 (1+epsilon) 倍平均值时选择优先级次之的节点
+func WithPeerOptionsBoundedLoad(epsilon float64) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.boundedLoad = epsilon
+	}
+}
+
+// WithPeerOptionsTopology 设置自身的拓扑标签并随注册信息发布,
+// 副本分散到不同的 zone、rack、host, 读取副本时优先本节点与同 zone 的节点
+func WithPeerOptionsTopology(zone, rack, host string) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.zone = zone
+		option.rack = rack
+		option.host = host
+	}
+}
+
+// WithPeerOptionsWeight 设置自身权重, 运行时可以通过 SetWeight 修改
+func WithPeerOptionsWeight(weight int32) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.weight = weight
+	}
+}
+
+// WithPeerOptionsCapacity 发布自身缓存容量, 未设置权重时每 64MB 容量计 1 个权重
+func WithPeerOptionsCapacity(bytes int64) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.capacity = bytes
+	}
+}
+
+// WithPeerOptionsLabels 发布自定义标签, 如版本、机型
+func WithPeerOptionsLabels(labels map[string]string) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.labels = labels
+	}
+}
+
+// WithPeerOptionsPoolSize 每个 peer 建立 size 个连接, 请求轮询使用
+func WithPeerOptionsPoolSize(size int) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		if size > 0 {
+			option.poolSize = size
+		}
+	}
+}
+
+// WithPeerOptionsKeepalive 设置连接空闲 interval 后发送 ping, 超过 timeout 未响应则关闭连接
+func WithPeerOptionsKeepalive(interval, timeout time.Duration) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.keepalive.Time = interval
+		option.keepalive.Timeout = timeout
+	}
+}
+
+// WithPeerOptionsDialOptions 追加 grpc 拨号参数, 如 TLS 凭证、拦截器
+func WithPeerOptionsDialOptions(opts ...grpc.DialOption) func(option *PeerOption) {
+	return func(option *PeerOption) {
+		option.dialOptions = append(option.dialOptions, opts...)
+	}
+}
+
+func (o *PeerOption) grpcDialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(o.keepalive),
+	}
+	// 用户参数在后, 可以覆盖默认参数
+	return append(opts, o.dialOptions...)
+}
+
+// grpcServerOption 允许其他 peer 按照相同的保活间隔发送 ping
+func (o *PeerOption) grpcServerOption() grpc.ServerOption {
+	return grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             o.keepalive.Time,
+		PermitWithoutStream: true,
+	})
+}
+
+// initRegistry 未指定注册中心时使用 etcd
+func (o *PeerOption) initRegistry(endpoints []string) {
+	if o.registry != nil {
+		return
+	}
+	r, err := registry.NewEtcd(serviceTarget, defaultLeaseExpire, endpoints...)
+	if err != nil {
+		panic(fmt.Errorf("failed to new etcd registry, err: %v", err))
+	}
+	o.registry = r
+}
+
+// nodeWeight 自身权重, 未设置时按容量计算, 至少为 1
+func (o *PeerOption) nodeWeight() int32 {
+	if o.weight > 0 {
+		return o.weight
+	}
+	if weight := o.capacity / capacityPerWeight; weight > 1 {
+		return int32(min(weight, math.MaxInt32))
+	}
+	return 1
+}
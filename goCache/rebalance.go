@@ -0,0 +1,101 @@
+package goCache
+
+import (
+	"context"
+	"goCache/goCache/cache"
+	"log"
+	"time"
+)
+
+const handoffTimeout = time.Second * 10
+
+// rebalancer 集群成员变更后通知 group 处理不再属于本节点的 key
+type rebalancer interface {
+	onRebalance(fn func(moved func(key string) bool))
+}
+
+// rebalance 删除 mainCache 中不再属于本节点的 key, 启用 handoff 时先写入新的所有者,
+// 避免再平衡后继续提供过期的数据直到 TTL 到期
+// moved 判断 key 的主节点区间是否已从本节点移走, 多副本时副本集合可能变化而主节点不变, 因此检查所有 key
+// 一致性hash环以外的 picker 无法计算区间, moved 对所有 key 返回 true
+func (c *Group) rebalance(moved func(key string) bool) {
+	lister, ok := c.mainCache.(cache.Lister)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handoffTimeout)
+	defer cancel()
+	var dropped int
+	for _, key := range lister.Keys() {
+		if c.replicas == 1 && !moved(key) {
+			continue
+		}
+		owners := c.owners(key)
+		if len(owners) == 0 || owned(owners) {
+			continue
+		}
+		if c.handoff {
+			c.handoffKey(ctx, key, owners)
+		}
+		c.mainCache.Delete(key)
+		dropped++
+	}
+	if dropped > 0 {
+		log.Printf("[%s] rebalance, dropped %d keys\n", c.name, dropped)
+	}
+}
+
+// handoffKey 将 key 以剩余的过期时间写入新的所有者, 已过期的 key 不再转移, 失败时只记录日志
+func (c *Group) handoffKey(ctx context.Context, key string, owners []PeerGetter) {
+	v, ok := c.mainCache.Get(key)
+	if !ok {
+		return
+	}
+	view := v.(ByteView)
+	now := time.Now()
+	var expire, soft time.Duration
+	if view.expire != 0 {
+		if expire = time.UnixMilli(view.expire).Sub(now); expire <= 0 {
+			return
+		}
+	}
+	if view.soft != 0 {
+		soft = time.UnixMilli(view.soft).Sub(now)
+	}
+	for _, peer := range owners {
+		var err error
+		if view.loaded {
+			err = peer.Handoff(ctx, c.name, key, view.Slice(), soft, expire)
+		} else {
+			err = peer.Set(ctx, c.name, key, view.Slice(), expire)
+		}
+		peerRequests.Inc(c.name, peer.Name(), "set", result(err))
+		if err != nil {
+			log.Printf("[%s] failed to hand off key %s to peer %s, err: %v\n", c.name, key, peer.Name(), err)
+		}
+	}
+}
+
+// setHandoff 保存其他节点再平衡时转移的通过 Getter 加载的数据, soft 不为 0 时保留软过期时间, 已超过软过期时间时为负数
+func (c *Group) setHandoff(key string, value []byte, soft, expire time.Duration) {
+	c.deleteNegative(key)
+	now := time.Now()
+	v := ByteView{b: value, loaded: true}
+	if soft != 0 {
+		v.soft = now.Add(soft).UnixMilli()
+	}
+	if expire > 0 {
+		v.expire = now.Add(expire).UnixMilli()
+	}
+	c.mainCache.Set(key, v, expire)
+}
+
+// owned owners 中是否包含本节点
+func owned(owners []PeerGetter) bool {
+	for _, peer := range owners {
+		if peer == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package goCache
+
+import (
+	"context"
+	"fmt"
+	"goCache/goCache/consistent"
+	"goCache/goCache/registry"
+	"goCache/pb"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGroup_Rebalance(t *testing.T) {
+	for _, name := range []string{"ring", "rendezvous", "jump", "maglev"} {
+		for _, handoff := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/handoff=%v", name, handoff), func(t *testing.T) {
+				picker, err := consistent.NewPicker(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				self := "http://localhost:8041"
+				remote := &fakeGetter{name: "http://localhost:8042"}
+				peer := NewHTTPPoolWithOptions(self, nil, WithPeerOptionsRegistry(registry.NewStatic()), WithPeerOptionsPicker(picker))
+				peer.newGetter = func(name string, addr string) (PeerGetter, error) {
+					return remote, nil
+				}
+				peer.SetService(&pb.ServiceNode{Name: self, Addr: self, Weight: 1})
+
+				group := NewGroup(fmt.Sprintf("rebalance-%s-%v", name, handoff), GetterFunc(func(key string) ([]byte, error) {
+					return []byte(key), nil
+				}), WithCacheOptionsHandoff(handoff))
+				group.RegisterPeer(peer)
+				for i := 0; i < 100; i++ {
+					key := fmt.Sprintf("key%d", i)
+					group.setLocally(key, []byte(key), time.Minute)
+				}
+
+				peer.SetService(&pb.ServiceNode{Name: remote.name, Addr: remote.name, Weight: 1})
+				// 属于新节点的 key 被删除, 仍属于本节点的 key 保留
+				var moved int
+				waitFor(t, func() bool {
+					moved = 0
+					for i := 0; i < 100; i++ {
+						key := fmt.Sprintf("key%d", i)
+						_, remoteKey := peer.PickPeer(key)
+						if remoteKey {
+							moved++
+						}
+						if remoteKey == group.cached(key) {
+							return false
+						}
+					}
+					return true
+				})
+				if moved == 0 || moved == 100 {
+					t.Fatalf("expected some keys to move, got %d", moved)
+				}
+
+				remote.mu.Lock()
+				defer remote.mu.Unlock()
+				if handoff && len(remote.sets) != moved {
+					t.Fatalf("expected %d keys to be handed off, got %d", moved, len(remote.sets))
+				}
+				if !handoff && len(remote.sets) != 0 {
+					t.Fatalf("expected no handoff, got %d", len(remote.sets))
+				}
+			})
+		}
+	}
+}
+
+func TestGroup_HandoffTTL(t *testing.T) {
+	group := NewGroup("handoff-ttl", GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithCacheOptionsStaleWhileRevalidate(time.Minute, time.Hour))
+	if _, err := group.Get("loaded"); err != nil {
+		t.Fatalf("Get failed, err: %v", err)
+	}
+	group.setLocally("set", []byte("v"), time.Minute*10)
+	group.setLocally("forever", []byte("v"), 0)
+	group.mainCache.Set("expired", ByteView{b: []byte("v"), expire: time.Now().Add(-time.Second).UnixMilli()}, time.Minute)
+
+	remote := &fakeGetter{}
+	for _, key := range []string{"loaded", "set", "forever", "expired"} {
+		group.handoffKey(context.Background(), key, []PeerGetter{remote})
+	}
+	// 通过 Getter 加载的数据保留剩余的软过期与过期时间
+	if soft := remote.softs["loaded"]; soft <= 0 || soft > time.Minute {
+		t.Fatalf("expected remaining soft ttl, got %v", soft)
+	}
+	if expire := remote.expires["loaded"]; expire <= time.Minute || expire > time.Hour {
+		t.Fatalf("expected remaining hard ttl, got %v", expire)
+	}
+	// 写入的数据保留剩余的过期时间, 永不过期的数据仍然永不过期
+	if expire := remote.expires["set"]; expire <= 0 || expire > time.Minute*10 {
+		t.Fatalf("expected remaining ttl, got %v", expire)
+	}
+	if _, ok := remote.sets["forever"]; !ok || remote.expires["forever"] != 0 {
+		t.Fatalf("expected forever to be handed off without ttl, got %v", remote.expires["forever"])
+	}
+	// 已过期的数据不再转移
+	if _, ok := remote.sets["expired"]; ok {
+		t.Fatal("expected expired key not to be handed off")
+	}
+
+	// 新的所有者保留软过期时间, 已超过软过期时间的数据仍然在后台刷新
+	owner := NewGroup("handoff-ttl-owner", GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithCacheOptionsStaleWhileRevalidate(time.Minute, time.Hour))
+	srv := httptest.NewServer(&HTTPPool{})
+	defer srv.Close()
+	if err := NewHTTPGetter("owner", srv.URL).Handoff(context.Background(), "handoff-ttl-owner", "loaded", []byte("v"), -time.Second, time.Minute); err != nil {
+		t.Fatalf("Handoff failed, err: %v", err)
+	}
+	v, ok := owner.mainCache.Get("loaded")
+	if !ok || !v.(ByteView).loaded || !v.(ByteView).stale(time.Now().UnixMilli()) {
+		t.Fatalf("expected stale loaded entry, got %v %v", v, ok)
+	}
+}
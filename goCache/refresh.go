@@ -0,0 +1,142 @@
+package goCache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshHits    = 2
+	defaultRefreshWorkers = 4
+	minRefreshAhead       = time.Millisecond * 10
+	maxTrackedKeys        = 10000
+)
+
+// hotKeys 统计一个周期内 key 的访问次数, 最多记录 maxTrackedKeys 个 key
+type hotKeys struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (h *hotKeys) touch(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.counts == nil {
+		h.counts = make(map[string]int)
+	}
+	if _, ok := h.counts[key]; ok || len(h.counts) < maxTrackedKeys {
+		h.counts[key]++
+	}
+}
+
+// take 返回访问次数不少于 min 的 key 并开始新的周期
+func (h *hotKeys) take(min int) []string {
+	h.mu.Lock()
+	counts := h.counts
+	h.counts = nil
+	h.mu.Unlock()
+	var keys []string
+	for key, n := range counts {
+		if n >= min {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// refresher 提前刷新即将过期的热点 key, 刷新由固定数量的 worker 执行
+type refresher struct {
+	ahead   time.Duration // 剩余时间不超过 ahead 时刷新
+	minHits int           // 一个周期内至少访问 minHits 次才刷新
+	hot     hotKeys
+	jobs    chan string
+	stop    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+func (c *Group) startRefresher() {
+	if c.refreshAhead <= 0 {
+		return
+	}
+	r := &refresher{
+		ahead:   c.refreshAhead,
+		minHits: c.refreshHits,
+		jobs:    make(chan string, c.refreshWorkers),
+		stop:    make(chan struct{}),
+	}
+	r.wg.Add(c.refreshWorkers + 1)
+	for i := 0; i < c.refreshWorkers; i++ {
+		go c.refreshWorker(r)
+	}
+	go c.refreshLoop(r)
+	c.refresher = r
+}
+
+// track 记录一次访问
+func (c *Group) track(key string) {
+	if c.refresher != nil {
+		c.refresher.hot.touch(key)
+	}
+}
+
+// refreshLoop 每隔 ahead/2 检查上个周期的热点 key, 剩余时间不超过 ahead 时交给 worker, worker 繁忙时跳过
+func (c *Group) refreshLoop(r *refresher) {
+	defer r.wg.Done()
+	defer close(r.jobs)
+	ticker := time.NewTicker(r.ahead / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+		now := time.Now()
+		for _, key := range r.hot.take(r.minHits) {
+			v, ok := c.mainCache.Get(key)
+			if !ok || !v.(ByteView).expiring(now.Add(r.ahead).UnixMilli()) {
+				continue
+			}
+			select {
+			case r.jobs <- key:
+			case <-r.stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (c *Group) refreshWorker(r *refresher) {
+	defer r.wg.Done()
+	for key := range r.jobs {
+		// 再平衡后 key 可能已属于其他节点, 由新的所有者加载
+		if owners := c.owners(key); len(owners) > 0 && !owned(owners) {
+			continue
+		}
+		if _, loading := c.refresh.LoadOrStore(key, struct{}{}); loading {
+			continue
+		}
+		c.stats.refreshAheads.Add(1)
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		_, err := c.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return c.loadLocally(ctx, key)
+		})
+		cancel()
+		c.refresh.Delete(key)
+		if err != nil {
+			log.Printf("[%s] failed to refresh key %s ahead, err: %v\n", c.name, key, err)
+		}
+	}
+}
+
+// close 停止检查并等待 worker 退出
+func (r *refresher) close() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	r.wg.Wait()
+}
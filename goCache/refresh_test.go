@@ -0,0 +1,91 @@
+package goCache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_RefreshAhead(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		loads = make(map[string]int)
+	)
+	group := NewGroup("refresh-ahead", GetterFunc(func(key string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[key]++
+		return []byte(fmt.Sprintf("%s-v%d", key, loads[key])), nil
+	}), WithCacheOptionsStaleWhileRevalidate(time.Millisecond*200, time.Second),
+		WithCacheOptionsRefreshAhead(time.Millisecond*120, 1, 2))
+	defer group.Close()
+
+	group.Get("cold")
+	// 持续访问的热点 key 在软过期之前被刷新, 调用方不会读到过期数据
+	for start := time.Now(); time.Since(start) < time.Millisecond*500; time.Sleep(time.Millisecond * 5) {
+		if _, err := group.Get("hot"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := group.Stats()
+	if stats.StaleHits != 0 {
+		t.Errorf("expected no stale hits, got %d", stats.StaleHits)
+	}
+	if stats.RefreshAheads < 2 {
+		t.Errorf("expected hot key to be refreshed ahead, got %d", stats.RefreshAheads)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if loads["hot"] < 3 {
+		t.Errorf("expected hot key to be reloaded, got %d loads", loads["hot"])
+	}
+	// 没有再次访问的 key 不刷新
+	if loads["cold"] != 1 {
+		t.Errorf("expected cold key to be loaded once, got %d", loads["cold"])
+	}
+}
+
+func TestGroup_RefreshAheadOwner(t *testing.T) {
+	var loads atomic.Int32
+	group := NewGroup("refresh-ahead-owner", GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		return []byte(key), nil
+	}), WithCacheOptionsRefreshAhead(time.Nanosecond, 1, 1))
+	defer group.Close()
+	if group.refreshAhead != minRefreshAhead {
+		t.Fatalf("expected refreshAhead to be clamped to %v, got %v", minRefreshAhead, group.refreshAhead)
+	}
+	group.RegisterPeer(&fakePeer{getter: &fakeGetter{}})
+
+	// 再平衡前缓存的 key 已属于其他节点, 不再提前刷新
+	group.mainCache.Set("remote-1", ByteView{b: []byte("v"), expire: time.Now().Add(time.Millisecond * 15).UnixMilli(), loaded: true}, time.Second)
+	for start := time.Now(); time.Since(start) < time.Millisecond*50; time.Sleep(time.Millisecond) {
+		group.track("remote-1")
+	}
+	if n := loads.Load(); n != 0 {
+		t.Fatalf("expected no refresh for a key owned by another node, got %d loads", n)
+	}
+}
+
+func TestHotKeys(t *testing.T) {
+	var h hotKeys
+	h.touch("a")
+	h.touch("a")
+	h.touch("b")
+	if keys := h.take(2); len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("expected [a], got %v", keys)
+	}
+	// 每个周期重新计数
+	if keys := h.take(1); len(keys) != 0 {
+		t.Fatalf("expected counts to be reset, got %v", keys)
+	}
+	for i := 0; i < maxTrackedKeys+10; i++ {
+		h.touch(fmt.Sprintf("key%d", i))
+	}
+	if keys := h.take(1); len(keys) != maxTrackedKeys {
+		t.Fatalf("expected %d tracked keys, got %d", maxTrackedKeys, len(keys))
+	}
+}
@@ -0,0 +1,330 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"goCache/pb"
+	"google.golang.org/protobuf/proto"
+	"log"
+	"sync"
+	"time"
+)
+
+// Etcd 基于 etcd 租约的注册中心, 节点以 prefix-leaseID 为 key 注册
+//
+// 租约丢失 (如网络分区导致租约过期) 后会以指数退避重新创建租约并注册,
+// 监听中断后从最后处理的版本继续监听, 版本已被压缩时重新获取全部节点并推送差异
+// 重新注册期间同一节点可能短暂存在多个 key, 事件按节点名称合并, 最后一个 key 删除时才推送 EventDelete
+type Etcd struct {
+	cli         *clientv3.Client
+	prefix      string
+	leaseExpire int64 // 租约过期时间, 单位秒
+	mu          sync.Mutex
+	lease       clientv3.LeaseID // 当前租约, 重新注册期间为 0
+	node        *pb.ServiceNode
+	stop        context.CancelFunc // 停止续租与重新注册
+}
+
+func NewEtcd(prefix string, leaseExpire int64, endpoints ...string) (*Etcd, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints: endpoints,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to new etcd client, err: %w", err)
+	}
+	return &Etcd{
+		cli:         cli,
+		prefix:      prefix,
+		leaseExpire: leaseExpire,
+	}, nil
+}
+
+// Register 注册节点, 已注册时使用当前租约更新节点信息, 正在重新注册时由重新注册写入
+func (e *Etcd) Register(ctx context.Context, node *pb.ServiceNode) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stop != nil {
+		if e.lease != 0 {
+			if err := e.put(ctx, e.lease, node); err != nil {
+				return err
+			}
+		}
+		e.node = node
+		return nil
+	}
+
+	superviseCtx, stop := context.WithCancel(context.Background())
+	lease, keepalive, cancel, err := e.register(ctx, superviseCtx, node)
+	if err != nil {
+		stop()
+		return err
+	}
+	e.lease, e.node, e.stop = lease, node, stop
+	go e.supervise(superviseCtx, keepalive, cancel)
+	return nil
+}
+
+// register 创建租约并注册节点, 续租持续到 keepaliveCtx 结束或返回的 cancel 被调用
+// 创建租约后的任何步骤失败都会停止续租并撤销租约, 避免失败的重试遗留一直续租的租约
+func (e *Etcd) register(ctx, keepaliveCtx context.Context, node *pb.ServiceNode) (clientv3.LeaseID, <-chan *clientv3.LeaseKeepAliveResponse, context.CancelFunc, error) {
+	// 创建租约
+	lease, err := e.cli.Grant(ctx, e.leaseExpire)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed grant lease, err: %w", err)
+	}
+	// 设置租约不过期
+	leaseCtx, cancel := context.WithCancel(keepaliveCtx)
+	keepalive, err := e.cli.KeepAlive(leaseCtx, lease.ID)
+	if err == nil {
+		err = e.put(ctx, lease.ID, node)
+	}
+	if err != nil {
+		cancel()
+		e.revoke(lease.ID)
+		return 0, nil, nil, err
+	}
+	return lease.ID, keepalive, cancel, nil
+}
+
+// revoke 撤销注册失败或已注销的租约, 不使用可能已经结束的请求 ctx
+func (e *Etcd) revoke(lease clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.leaseExpire)*time.Second)
+	defer cancel()
+	if _, err := e.cli.Revoke(ctx, lease); err != nil {
+		log.Println("failed to revoke lease, err: ", err)
+	}
+}
+
+func (e *Etcd) put(ctx context.Context, lease clientv3.LeaseID, node *pb.ServiceNode) error {
+	key := fmt.Sprintf("%s-%d", e.prefix, lease)
+	value, err := proto.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ServiceNode, err: %w", err)
+	}
+	if _, err = e.cli.Put(ctx, key, string(value), clientv3.WithLease(lease)); err != nil {
+		return fmt.Errorf("register service to etcd failed, err: %w", err)
+	}
+	return nil
+}
+
+// supervise 续租中断后重新创建租约并注册
+func (e *Etcd) supervise(ctx context.Context, keepalive <-chan *clientv3.LeaseKeepAliveResponse, cancelKeepalive context.CancelFunc) {
+	var b backoff
+	for {
+		for range keepalive {
+		}
+		cancelKeepalive()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("关闭租约, 重新注册")
+		e.mu.Lock()
+		e.lease = 0
+		e.mu.Unlock()
+		for {
+			select {
+			case <-time.After(b.next()):
+			case <-ctx.Done():
+				return
+			}
+			e.mu.Lock()
+			node := e.node
+			e.mu.Unlock()
+			reqCtx, cancel := context.WithTimeout(ctx, time.Duration(e.leaseExpire)*time.Second)
+			lease, ch, cancelCh, err := e.register(reqCtx, ctx, node)
+			cancel()
+			if err != nil {
+				log.Println("failed to re-register, err: ", err)
+				continue
+			}
+			e.mu.Lock()
+			if ctx.Err() != nil {
+				// 重新注册期间已经注销
+				e.mu.Unlock()
+				cancelCh()
+				e.revoke(lease)
+				return
+			}
+			e.lease = lease
+			// 重新注册期间节点信息已更新
+			if e.node != node {
+				if err := e.put(ctx, lease, e.node); err != nil {
+					log.Println("failed to update node after re-register, err: ", err)
+				}
+			}
+			e.mu.Unlock()
+			keepalive, cancelKeepalive = ch, cancelCh
+			b.reset()
+			break
+		}
+	}
+}
+
+func (e *Etcd) Watch(ctx context.Context) (<-chan Event, error) {
+	// 初始获取服务节点
+	nodes, rev, err := e.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Event, eventBuffer)
+	go func() {
+		defer close(ch)
+		var members map[string]*pb.ServiceNode
+		// send 推送 nodes 对应的成员与上次推送时的差异
+		send := func(nodes map[string]etcdNode) bool {
+			next := byName(nodes)
+			for _, ev := range diff(members, next) {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			members = next
+			return true
+		}
+		if !send(nodes) {
+			return
+		}
+		var b backoff
+		for {
+			compacted, ok := e.watch(ctx, nodes, &rev, send, &b)
+			if !ok || ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-time.After(b.next()):
+			case <-ctx.Done():
+				return
+			}
+			if !compacted {
+				continue
+			}
+			// 版本已被压缩, 重新获取全部节点并推送差异
+			snapshot, snapshotRev, err := e.list(ctx)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if !send(snapshot) {
+				return
+			}
+			nodes, rev = snapshot, snapshotRev
+		}
+	}()
+	return ch, nil
+}
+
+// watch 从 rev 之后开始监听, 直到监听中断; 返回版本是否已被压缩, 以及是否可以继续
+func (e *Etcd) watch(ctx context.Context, nodes map[string]etcdNode, rev *int64, send func(map[string]etcdNode) bool, b *backoff) (bool, bool) {
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	watchCh := e.cli.Watch(watchCtx, e.prefix, clientv3.WithPrefix(), clientv3.WithRev(*rev+1))
+	for wresp := range watchCh {
+		if wresp.CompactRevision != 0 {
+			log.Println("watch revision compacted, ", wresp.CompactRevision)
+			return true, true
+		}
+		if err := wresp.Err(); err != nil {
+			log.Println("watch interrupted, err: ", err)
+			return false, true
+		}
+		for _, ev := range wresp.Events {
+			key := string(ev.Kv.Key)
+			switch ev.Type {
+			case mvccpb.PUT:
+				node, err := unmarshalNode(ev.Kv.Value)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				nodes[key] = etcdNode{node: node, rev: ev.Kv.ModRevision}
+			case mvccpb.DELETE:
+				delete(nodes, key)
+			}
+		}
+		if !send(nodes) {
+			return false, false
+		}
+		*rev = wresp.Header.Revision
+		b.reset()
+	}
+	return false, true
+}
+
+// etcdNode etcd 中的一个注册 key 对应的节点
+type etcdNode struct {
+	node *pb.ServiceNode
+	rev  int64 // 最后修改的版本
+}
+
+// byName 按节点名称合并注册 key, 同名时使用最后修改的 key
+func byName(nodes map[string]etcdNode) map[string]*pb.ServiceNode {
+	members := make(map[string]*pb.ServiceNode, len(nodes))
+	revs := make(map[string]int64, len(nodes))
+	for _, n := range nodes {
+		name := n.node.GetName()
+		if _, ok := members[name]; ok && revs[name] > n.rev {
+			continue
+		}
+		members[name], revs[name] = n.node, n.rev
+	}
+	return members
+}
+
+// list 获取所有注册 key 与当前版本
+func (e *Etcd) list(ctx context.Context) (map[string]etcdNode, int64, error) {
+	resp, err := e.cli.Get(ctx, e.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get service nodes, err: %w", err)
+	}
+	nodes := make(map[string]etcdNode)
+	for _, kv := range resp.Kvs {
+		node, err := unmarshalNode(kv.Value)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		nodes[string(kv.Key)] = etcdNode{node: node, rev: kv.ModRevision}
+	}
+	return nodes, resp.Header.Revision, nil
+}
+
+func (e *Etcd) Deregister(ctx context.Context) error {
+	e.mu.Lock()
+	lease, stop := e.lease, e.stop
+	e.lease, e.node, e.stop = 0, nil, nil
+	e.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	// 停止重新注册, 撤销租约后 key 随之删除; 正在重新注册时没有有效的租约
+	stop()
+	if lease == 0 {
+		return nil
+	}
+	if _, err := e.cli.Revoke(ctx, lease); err != nil {
+		return fmt.Errorf("failed to revoke lease, err: %w", err)
+	}
+	return nil
+}
+
+func (e *Etcd) Close() error {
+	e.mu.Lock()
+	if e.stop != nil {
+		e.stop()
+	}
+	e.mu.Unlock()
+	return e.cli.Close()
+}
+
+func unmarshalNode(value []byte) (*pb.ServiceNode, error) {
+	node := &pb.ServiceNode{}
+	if err := proto.Unmarshal(value, node); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ServiceNode, err: %w", err)
+	}
+	return node, nil
+}
@@ -0,0 +1,179 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goCache/pb"
+	"google.golang.org/protobuf/proto"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultFileInterval = time.Second * 5
+
+// fileNode 成员文件中的节点, 文件内容为节点数组:
+//
+//	[{"name": "node1", "addr": "localhost:8001", "weight": 1, "zone": "az1"}]
+//
+// name 为空时使用 addr, weight 为空时为 1, zone、rack、host 为可选的拓扑标签
+type fileNode struct {
+	Name   string `json:"name"`
+	Addr   string `json:"addr"`
+	Weight int32  `json:"weight"`
+	Zone   string `json:"zone"`
+	Rack   string `json:"rack"`
+	Host   string `json:"host"`
+}
+
+// File 基于 JSON 文件的注册中心, 定期检查文件修改时间, 变更时重新加载成员
+type File struct {
+	path     string
+	interval time.Duration
+	mu       sync.Mutex
+	self     *pb.ServiceNode
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewFile interval 为检查文件变更的间隔, 不大于 0 时为 5s
+func NewFile(path string, interval time.Duration) *File {
+	if interval <= 0 {
+		interval = defaultFileInterval
+	}
+	return &File{
+		path:     path,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (f *File) Register(ctx context.Context, node *pb.ServiceNode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.self != nil && !proto.Equal(f.self, node) {
+		return ErrImmutable
+	}
+	f.self = node
+	return nil
+}
+
+func (f *File) Watch(ctx context.Context) (<-chan Event, error) {
+	nodes, modTime, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Event, eventBuffer)
+	go func() {
+		defer close(ch)
+		current := make(map[string]*pb.ServiceNode)
+		send := func(ev Event) bool {
+			select {
+			case ch <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			case <-f.done:
+				return false
+			}
+		}
+		// 与当前成员比较, 推送差异
+		apply := func(nodes map[string]*pb.ServiceNode) bool {
+			for _, ev := range diff(current, nodes) {
+				if !send(ev) {
+					return false
+				}
+			}
+			current = nodes
+			return true
+		}
+		if !apply(nodes) {
+			return
+		}
+
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-f.done:
+				return
+			}
+			stat, err := os.Stat(f.path)
+			if err != nil {
+				log.Println("failed to stat peers file, err: ", err)
+				continue
+			}
+			if stat.ModTime().Equal(modTime) {
+				continue
+			}
+			// 文件错误时保留当前成员
+			nodes, mt, err := f.load()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			modTime = mt
+			if !apply(nodes) {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (f *File) Deregister(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.self = nil
+	return nil
+}
+
+func (f *File) Close() error {
+	f.once.Do(func() {
+		close(f.done)
+	})
+	return nil
+}
+
+// load 读取成员文件, 自身节点总是成员之一
+func (f *File) load() (map[string]*pb.ServiceNode, time.Time, error) {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat peers file, err: %w", err)
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read peers file, err: %w", err)
+	}
+	var list []fileNode
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to unmarshal peers file %s, err: %w", f.path, err)
+	}
+	nodes := make(map[string]*pb.ServiceNode, len(list)+1)
+	for _, n := range list {
+		if n.Addr == "" {
+			return nil, time.Time{}, fmt.Errorf("peers file %s: node %q without addr", f.path, n.Name)
+		}
+		if n.Name == "" {
+			n.Name = n.Addr
+		}
+		if n.Weight <= 0 {
+			n.Weight = 1
+		}
+		nodes[n.Name] = &pb.ServiceNode{Name: n.Name, Addr: n.Addr, Weight: n.Weight, Zone: n.Zone, Rack: n.Rack, Host: n.Host}
+	}
+	f.mu.Lock()
+	if f.self != nil {
+		if _, ok := nodes[f.self.GetName()]; !ok {
+			nodes[f.self.GetName()] = f.self
+		}
+	}
+	f.mu.Unlock()
+	return nodes, stat.ModTime(), nil
+}
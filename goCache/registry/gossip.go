@@ -0,0 +1,660 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCache/pb"
+	"google.golang.org/protobuf/proto"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeInterval    = time.Second
+	defaultProbeTimeout     = time.Millisecond * 500
+	defaultSuspicionTimeout = time.Second * 5
+	defaultIndirectChecks   = 3
+	defaultRetransmitMult   = 4
+	maxPiggyback            = 8         // 每个消息最多携带的广播数
+	maxPacketSize           = 64 * 1024 // UDP 包最大长度
+)
+
+// GossipConfig SWIM 协议参数
+type GossipConfig struct {
+	AdvertiseAddr    string        // 对外公布的 gossip 地址, 为空时使用监听地址
+	ProbeInterval    time.Duration // 探测间隔
+	ProbeTimeout     time.Duration // 直接探测超时, 之后进行间接探测
+	SuspicionTimeout time.Duration // 怀疑状态持续时间, 之后认为节点死亡
+	IndirectChecks   int           // 间接探测的节点数
+	RetransmitMult   int           // 每条广播发送 RetransmitMult * log10(n+1) 次
+}
+
+func DefaultGossipConfig() GossipConfig {
+	return GossipConfig{
+		ProbeInterval:    defaultProbeInterval,
+		ProbeTimeout:     defaultProbeTimeout,
+		SuspicionTimeout: defaultSuspicionTimeout,
+		IndirectChecks:   defaultIndirectChecks,
+		RetransmitMult:   defaultRetransmitMult,
+	}
+}
+
+type member struct {
+	node         *pb.ServiceNode
+	addr         string // gossip 地址
+	incarnation  uint64
+	state        pb.MemberState
+	suspectTimer *time.Timer
+}
+
+func (m *member) proto() *pb.GossipMember {
+	return &pb.GossipMember{
+		Node:        m.node,
+		GossipAddr:  m.addr,
+		Incarnation: m.incarnation,
+		State:       m.state,
+	}
+}
+
+func (m *member) active() bool {
+	return m.state == pb.MemberState_ALIVE || m.state == pb.MemberState_SUSPECT
+}
+
+type broadcast struct {
+	member    *pb.GossipMember
+	transmits int // 剩余发送次数
+}
+
+// Gossip 基于 SWIM 协议的去中心化注册中心, 节点之间通过 UDP 探测存活并传播成员变更:
+//
+//   - 每个探测周期向一个成员发送 PING, 超时后请 IndirectChecks 个成员代为探测 (PING_REQ)
+//   - 仍无响应的成员进入 SUSPECT 状态, SuspicionTimeout 内未反驳则认为 DEAD
+//   - 成员变更附加在 PING/ACK 上传播, incarnation 较大的消息覆盖较小的
+//   - 节点收到关于自身的 SUSPECT/DEAD 时增大 incarnation 进行反驳
+type Gossip struct {
+	config GossipConfig
+	conn   *net.UDPConn
+	addr   string
+	seeds  []string
+	done   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	self       *member
+	left       bool
+	probing    bool
+	members    map[string]*member // 节点名称 -> 成员, 包括已死亡的成员
+	probeOrder []string
+	broadcasts []*broadcast
+	seq        uint64
+	pending    map[uint64]chan struct{} // 等待 ACK 的探测
+	watchers   []*watcher
+}
+
+// NewGossip 在 bind 上监听 UDP, Register 后通过 seeds 加入集群
+func NewGossip(bind string, config GossipConfig, seeds ...string) (*Gossip, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", bind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve gossip addr %s, err: %w", bind, err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen gossip addr %s, err: %w", bind, err)
+	}
+	addr := config.AdvertiseAddr
+	if addr == "" {
+		addr = conn.LocalAddr().String()
+	}
+	g := &Gossip{
+		config:  config,
+		conn:    conn,
+		addr:    addr,
+		seeds:   seeds,
+		done:    make(chan struct{}),
+		members: make(map[string]*member),
+		pending: make(map[uint64]chan struct{}),
+	}
+	g.wg.Add(1)
+	go g.receive()
+	return g, nil
+}
+
+// Addr gossip 地址
+func (g *Gossip) Addr() string {
+	return g.addr
+}
+
+// Register 加入集群, 重复调用时增大 incarnation 传播节点的新信息 (如权重)
+func (g *Gossip) Register(ctx context.Context, node *pb.ServiceNode) error {
+	g.mu.Lock()
+	if g.self != nil && !g.left {
+		g.self.node = node
+		g.self.incarnation++
+		g.addBroadcast(g.self.proto())
+		g.notify(Event{Type: EventPut, Node: node})
+		g.mu.Unlock()
+		return nil
+	}
+	var incarnation uint64
+	if g.self != nil {
+		incarnation = g.self.incarnation + 1
+	}
+	g.self = &member{
+		node:        node,
+		addr:        g.addr,
+		incarnation: incarnation,
+		state:       pb.MemberState_ALIVE,
+	}
+	g.left = false
+	g.members[node.GetName()] = g.self
+	g.addBroadcast(g.self.proto())
+	g.notify(Event{Type: EventPut, Node: node})
+	self := g.self.proto()
+	g.mu.Unlock()
+
+	// 向种子节点发送 PING, 种子节点会回复完整的成员列表
+	for _, seed := range g.seeds {
+		if seed != g.addr {
+			g.send(seed, &pb.GossipMessage{Type: pb.GossipType_PING, Members: []*pb.GossipMember{self}})
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.probing {
+		g.probing = true
+		g.wg.Add(1)
+		go g.probeLoop()
+	}
+	return nil
+}
+
+func (g *Gossip) Watch(ctx context.Context) (<-chan Event, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	w := newWatcher(ctx, g.done)
+	for _, m := range g.members {
+		if m.active() {
+			w.push(Event{Type: EventPut, Node: m.node})
+		}
+	}
+	g.watchers = append(g.watchers, w)
+	return w.ch, nil
+}
+
+// Deregister 通知所有成员自身离开集群
+func (g *Gossip) Deregister(ctx context.Context) error {
+	g.mu.Lock()
+	if g.self == nil || g.left {
+		g.mu.Unlock()
+		return nil
+	}
+	g.left = true
+	g.self.state = pb.MemberState_LEFT
+	g.self.incarnation++
+	msg := &pb.GossipMessage{Type: pb.GossipType_PING, Members: []*pb.GossipMember{g.self.proto()}}
+	var addrs []string
+	for _, m := range g.members {
+		if m != g.self && m.active() {
+			addrs = append(addrs, m.addr)
+		}
+	}
+	g.notify(Event{Type: EventDelete, Node: g.self.node})
+	g.mu.Unlock()
+
+	for _, addr := range addrs {
+		g.send(addr, msg)
+	}
+	return nil
+}
+
+func (g *Gossip) Close() error {
+	var err error
+	g.once.Do(func() {
+		close(g.done)
+		err = g.conn.Close()
+		g.wg.Wait()
+	})
+	return err
+}
+
+func (g *Gossip) receive() {
+	defer g.wg.Done()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Println("failed to read gossip packet, err: ", err)
+			continue
+		}
+		msg := &pb.GossipMessage{}
+		if err = proto.Unmarshal(buf[:n], msg); err != nil {
+			log.Println("failed to unmarshal gossip message from ", from, ", err: ", err)
+			continue
+		}
+		g.handle(msg)
+	}
+}
+
+func (g *Gossip) handle(msg *pb.GossipMessage) {
+	g.mu.Lock()
+	known := g.knownAddr(msg.GetFrom())
+	for _, m := range msg.GetMembers() {
+		g.merge(m)
+	}
+	g.mu.Unlock()
+
+	switch msg.GetType() {
+	case pb.GossipType_PING:
+		ack := &pb.GossipMessage{Type: pb.GossipType_ACK, Seq: msg.GetSeq()}
+		if !known {
+			// 新加入的节点需要完整的成员列表
+			ack.Members = g.snapshot()
+		}
+		g.send(msg.GetFrom(), ack)
+	case pb.GossipType_ACK:
+		g.mu.Lock()
+		if ch, ok := g.pending[msg.GetSeq()]; ok {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+		g.mu.Unlock()
+	case pb.GossipType_PING_REQ:
+		g.wg.Add(1)
+		go g.indirectProbe(msg.GetFrom(), msg.GetSeq(), msg.GetTarget())
+	}
+}
+
+// indirectProbe 代替 from 探测 target, 成功时以 from 的 seq 回复 ACK
+func (g *Gossip) indirectProbe(from string, seq uint64, target string) {
+	defer g.wg.Done()
+	ch, probeSeq := g.newPending()
+	defer g.deletePending(probeSeq)
+	g.send(target, &pb.GossipMessage{Type: pb.GossipType_PING, Seq: probeSeq})
+	select {
+	case <-ch:
+		g.send(from, &pb.GossipMessage{Type: pb.GossipType_ACK, Seq: seq})
+	case <-time.After(g.config.ProbeTimeout):
+	case <-g.done:
+	}
+}
+
+func (g *Gossip) probeLoop() {
+	defer g.wg.Done()
+	ticker := time.NewTicker(g.config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.probe()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+func (g *Gossip) probe() {
+	g.mu.Lock()
+	if g.left {
+		g.mu.Unlock()
+		return
+	}
+	target := g.nextTarget()
+	if target == nil {
+		g.mu.Unlock()
+		return
+	}
+	var (
+		name        = target.node.GetName()
+		addr        = target.addr
+		incarnation = target.incarnation
+	)
+	g.mu.Unlock()
+
+	ch, seq := g.newPending()
+	defer g.deletePending(seq)
+	g.send(addr, &pb.GossipMessage{Type: pb.GossipType_PING, Seq: seq})
+	select {
+	case <-ch:
+		return
+	case <-time.After(g.config.ProbeTimeout):
+	case <-g.done:
+		return
+	}
+
+	// 直接探测超时, 请其他成员代为探测
+	g.mu.Lock()
+	helpers := g.randomMembers(g.config.IndirectChecks, name)
+	g.mu.Unlock()
+	for _, helper := range helpers {
+		g.send(helper, &pb.GossipMessage{Type: pb.GossipType_PING_REQ, Seq: seq, Target: addr})
+	}
+	select {
+	case <-ch:
+		return
+	case <-time.After(g.config.ProbeInterval - g.config.ProbeTimeout):
+	case <-g.done:
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if m, ok := g.members[name]; ok && m.state == pb.MemberState_ALIVE && m.incarnation == incarnation {
+		log.Println("gossip member suspect, ", name)
+		g.merge(&pb.GossipMember{
+			Node:        m.node,
+			GossipAddr:  m.addr,
+			Incarnation: m.incarnation,
+			State:       pb.MemberState_SUSPECT,
+		})
+	}
+}
+
+// merge 合并其他节点传播的成员信息, 调用方持有锁
+func (g *Gossip) merge(gm *pb.GossipMember) {
+	name := gm.GetNode().GetName()
+	if g.self != nil && name == g.self.node.GetName() {
+		g.refute(gm)
+		return
+	}
+	local, ok := g.members[name]
+	if !ok {
+		if gm.GetState() != pb.MemberState_ALIVE && gm.GetState() != pb.MemberState_SUSPECT {
+			return
+		}
+		local = &member{
+			node:        gm.GetNode(),
+			addr:        gm.GetGossipAddr(),
+			incarnation: gm.GetIncarnation(),
+			state:       gm.GetState(),
+		}
+		g.members[name] = local
+		if local.state == pb.MemberState_SUSPECT {
+			g.startSuspicion(local)
+		}
+		g.addBroadcast(gm)
+		g.notify(Event{Type: EventPut, Node: local.node})
+		return
+	}
+
+	switch gm.GetState() {
+	case pb.MemberState_ALIVE:
+		if gm.GetIncarnation() <= local.incarnation {
+			return
+		}
+		changed := !local.active() || !proto.Equal(local.node, gm.GetNode())
+		local.stopSuspicion()
+		local.node = gm.GetNode()
+		local.addr = gm.GetGossipAddr()
+		local.incarnation = gm.GetIncarnation()
+		local.state = pb.MemberState_ALIVE
+		g.addBroadcast(gm)
+		if changed {
+			g.notify(Event{Type: EventPut, Node: local.node})
+		}
+	case pb.MemberState_SUSPECT:
+		if !local.active() || gm.GetIncarnation() < local.incarnation {
+			return
+		}
+		if local.state == pb.MemberState_SUSPECT && gm.GetIncarnation() == local.incarnation {
+			return
+		}
+		local.incarnation = gm.GetIncarnation()
+		local.state = pb.MemberState_SUSPECT
+		g.startSuspicion(local)
+		g.addBroadcast(gm)
+	case pb.MemberState_DEAD, pb.MemberState_LEFT:
+		if !local.active() || gm.GetIncarnation() < local.incarnation {
+			return
+		}
+		local.stopSuspicion()
+		local.incarnation = gm.GetIncarnation()
+		local.state = gm.GetState()
+		g.addBroadcast(gm)
+		g.notify(Event{Type: EventDelete, Node: local.node})
+	}
+}
+
+// refute 其他节点认为自身可疑或死亡时, 增大 incarnation 声明存活
+func (g *Gossip) refute(gm *pb.GossipMember) {
+	if g.left {
+		return
+	}
+	if gm.GetState() == pb.MemberState_ALIVE && gm.GetIncarnation() < g.self.incarnation {
+		return
+	}
+	if gm.GetState() == pb.MemberState_ALIVE && gm.GetIncarnation() == g.self.incarnation && proto.Equal(gm.GetNode(), g.self.node) {
+		return
+	}
+	if gm.GetIncarnation() >= g.self.incarnation {
+		g.self.incarnation = gm.GetIncarnation() + 1
+	}
+	g.addBroadcast(g.self.proto())
+}
+
+func (g *Gossip) startSuspicion(m *member) {
+	m.stopSuspicion()
+	incarnation := m.incarnation
+	m.suspectTimer = time.AfterFunc(g.config.SuspicionTimeout, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if m.state != pb.MemberState_SUSPECT || m.incarnation != incarnation {
+			return
+		}
+		log.Println("gossip member dead, ", m.node.GetName())
+		m.state = pb.MemberState_DEAD
+		g.addBroadcast(m.proto())
+		g.notify(Event{Type: EventDelete, Node: m.node})
+	})
+}
+
+func (m *member) stopSuspicion() {
+	if m.suspectTimer != nil {
+		m.suspectTimer.Stop()
+		m.suspectTimer = nil
+	}
+}
+
+// nextTarget 轮询打乱顺序后的成员, 调用方持有锁
+func (g *Gossip) nextTarget() *member {
+	for i := 0; i < 2; i++ {
+		for len(g.probeOrder) > 0 {
+			name := g.probeOrder[0]
+			g.probeOrder = g.probeOrder[1:]
+			if m, ok := g.members[name]; ok && m != g.self && m.active() {
+				return m
+			}
+		}
+		for name := range g.members {
+			g.probeOrder = append(g.probeOrder, name)
+		}
+		rand.Shuffle(len(g.probeOrder), func(i, j int) {
+			g.probeOrder[i], g.probeOrder[j] = g.probeOrder[j], g.probeOrder[i]
+		})
+	}
+	return nil
+}
+
+// randomMembers 随机选择 k 个存活成员的地址, 调用方持有锁
+func (g *Gossip) randomMembers(k int, exclude string) []string {
+	var candidates []string
+	for name, m := range g.members {
+		if m != g.self && name != exclude && m.state == pb.MemberState_ALIVE {
+			candidates = append(candidates, m.addr)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+func (g *Gossip) knownAddr(addr string) bool {
+	for _, m := range g.members {
+		if m.addr == addr && m.active() {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gossip) snapshot() []*pb.GossipMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	members := make([]*pb.GossipMember, 0, len(g.members))
+	for _, m := range g.members {
+		members = append(members, m.proto())
+	}
+	return members
+}
+
+// addBroadcast 加入广播队列, 同一成员只保留最新的消息, 调用方持有锁
+func (g *Gossip) addBroadcast(gm *pb.GossipMember) {
+	transmits := g.config.RetransmitMult * int(math.Ceil(math.Log10(float64(len(g.members)+1))))
+	if transmits < 1 {
+		transmits = 1
+	}
+	name := gm.GetNode().GetName()
+	for i, b := range g.broadcasts {
+		if b.member.GetNode().GetName() == name {
+			g.broadcasts = append(g.broadcasts[:i], g.broadcasts[i+1:]...)
+			break
+		}
+	}
+	g.broadcasts = append(g.broadcasts, &broadcast{member: gm, transmits: transmits})
+}
+
+// piggyback 取出最新的广播附加到消息上
+func (g *Gossip) piggyback() []*pb.GossipMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var members []*pb.GossipMember
+	for i := len(g.broadcasts) - 1; i >= 0 && len(members) < maxPiggyback; i-- {
+		b := g.broadcasts[i]
+		members = append(members, b.member)
+		b.transmits--
+		if b.transmits <= 0 {
+			g.broadcasts = append(g.broadcasts[:i], g.broadcasts[i+1:]...)
+		}
+	}
+	return members
+}
+
+func (g *Gossip) send(addr string, msg *pb.GossipMessage) {
+	msg.From = g.addr
+	msg.Members = append(msg.Members, g.piggyback()...)
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		log.Println("failed to marshal gossip message, err: ", err)
+		return
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		log.Println("failed to resolve gossip addr, err: ", err)
+		return
+	}
+	if _, err = g.conn.WriteToUDP(data, udpAddr); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Println("failed to send gossip message, err: ", err)
+	}
+}
+
+func (g *Gossip) newPending() (chan struct{}, uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seq++
+	ch := make(chan struct{}, 1)
+	g.pending[g.seq] = ch
+	return ch, g.seq
+}
+
+func (g *Gossip) deletePending(seq uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pending, seq)
+}
+
+// notify 推送事件给所有 watcher, 调用方持有锁
+func (g *Gossip) notify(ev Event) {
+	watchers := g.watchers[:0]
+	for _, w := range g.watchers {
+		if w.push(ev) {
+			watchers = append(watchers, w)
+		}
+	}
+	g.watchers = watchers
+}
+
+// watcher 缓存事件, 避免慢速的消费者阻塞协议
+type watcher struct {
+	ch     chan Event
+	mu     sync.Mutex
+	queue  []Event
+	signal chan struct{}
+	closed bool
+}
+
+func newWatcher(ctx context.Context, done <-chan struct{}) *watcher {
+	w := &watcher{
+		ch:     make(chan Event, eventBuffer),
+		signal: make(chan struct{}, 1),
+	}
+	go func() {
+		defer close(w.ch)
+		defer func() {
+			w.mu.Lock()
+			w.closed = true
+			w.mu.Unlock()
+		}()
+		for {
+			w.mu.Lock()
+			queue := w.queue
+			w.queue = nil
+			w.mu.Unlock()
+			for _, ev := range queue {
+				select {
+				case w.ch <- ev:
+				case <-ctx.Done():
+					return
+				case <-done:
+					return
+				}
+			}
+			select {
+			case <-w.signal:
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// push 加入事件队列, watcher 已结束时返回 false
+func (w *watcher) push(ev Event) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	w.queue = append(w.queue, ev)
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+	return true
+}
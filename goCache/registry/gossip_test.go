@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"goCache/pb"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testGossipConfig() GossipConfig {
+	return GossipConfig{
+		ProbeInterval:    time.Millisecond * 20,
+		ProbeTimeout:     time.Millisecond * 10,
+		SuspicionTimeout: time.Millisecond * 100,
+		IndirectChecks:   2,
+		RetransmitMult:   4,
+	}
+}
+
+// view 根据 Watch 事件维护的成员视图
+type view struct {
+	mu    sync.Mutex
+	nodes map[string]*pb.ServiceNode
+}
+
+func watchView(t *testing.T, r Registry) *view {
+	t.Helper()
+	ch, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &view{nodes: make(map[string]*pb.ServiceNode)}
+	go func() {
+		for ev := range ch {
+			v.mu.Lock()
+			switch ev.Type {
+			case EventPut:
+				v.nodes[ev.Node.GetName()] = ev.Node
+			case EventDelete:
+				delete(v.nodes, ev.Node.GetName())
+			}
+			v.mu.Unlock()
+		}
+	}()
+	return v
+}
+
+func (v *view) names() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	names := make([]string, 0, len(v.nodes))
+	for name := range v.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (v *view) weight(name string) int32 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.nodes[name].GetWeight()
+}
+
+func eventually(t *testing.T, timeout time.Duration, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestGossip(t *testing.T) {
+	var (
+		nodes []*Gossip
+		views []*view
+		seed  string
+	)
+	for i := 1; i <= 3; i++ {
+		var seeds []string
+		if seed != "" {
+			seeds = append(seeds, seed)
+		}
+		g, err := NewGossip("127.0.0.1:0", testGossipConfig(), seeds...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer g.Close()
+		if seed == "" {
+			seed = g.Addr()
+		}
+		name := fmt.Sprintf("node%d", i)
+		if err = g.Register(context.Background(), &pb.ServiceNode{Name: name, Addr: name, Weight: 1}); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, g)
+		views = append(views, watchView(t, g))
+	}
+
+	// 所有节点收敛到相同的成员
+	for i, v := range views {
+		eventually(t, time.Second, func() bool {
+			return v.names() == "node1,node2,node3"
+		}, "node%d expected 3 members, got %s", i+1, v.names())
+	}
+
+	// 权重变更传播到其他节点
+	nodes[1].Register(context.Background(), &pb.ServiceNode{Name: "node2", Addr: "node2", Weight: 3})
+	eventually(t, time.Second, func() bool {
+		return views[0].weight("node2") == 3 && views[2].weight("node2") == 3
+	}, "expected weight of node2 to be 3")
+
+	// 节点崩溃后被探测为死亡
+	nodes[2].Close()
+	for i, v := range views[:2] {
+		eventually(t, time.Second*2, func() bool {
+			return v.names() == "node1,node2"
+		}, "node%d expected node3 to be removed, got %s", i+1, v.names())
+	}
+
+	// 节点主动离开后立即被移除
+	nodes[1].Deregister(context.Background())
+	eventually(t, time.Millisecond*200, func() bool {
+		return views[0].names() == "node1"
+	}, "expected node2 to leave, got %s", views[0].names())
+}
+
+// TestGossip_Refute 被误判为可疑的节点增大 incarnation 反驳
+func TestGossip_Refute(t *testing.T) {
+	g1, err := NewGossip("127.0.0.1:0", testGossipConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	g2, err := NewGossip("127.0.0.1:0", testGossipConfig(), g1.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	g1.Register(context.Background(), &pb.ServiceNode{Name: "node1", Addr: "node1", Weight: 1})
+	g2.Register(context.Background(), &pb.ServiceNode{Name: "node2", Addr: "node2", Weight: 1})
+	v := watchView(t, g1)
+	eventually(t, time.Second, func() bool {
+		return v.names() == "node1,node2"
+	}, "expected 2 members, got %s", v.names())
+
+	g1.mu.Lock()
+	m := g1.members["node2"]
+	g1.merge(&pb.GossipMember{Node: m.node, GossipAddr: m.addr, Incarnation: m.incarnation, State: pb.MemberState_SUSPECT})
+	g1.mu.Unlock()
+
+	eventually(t, time.Second, func() bool {
+		g1.mu.Lock()
+		defer g1.mu.Unlock()
+		return g1.members["node2"].state == pb.MemberState_ALIVE
+	}, "expected node2 to refute suspicion")
+	// 反驳发生在怀疑超时之前, 成员未被移除
+	time.Sleep(testGossipConfig().SuspicionTimeout * 2)
+	if v.names() != "node1,node2" {
+		t.Fatalf("expected node2 to stay, got %s", v.names())
+	}
+}
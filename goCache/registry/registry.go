@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"goCache/pb"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventPut    EventType = iota // 节点加入或更新
+	EventDelete                  // 节点离开
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// eventBuffer Watch 返回的 channel 的缓冲大小, 使接收方可以一次取出多个事件批量应用
+const eventBuffer = 64
+
+// ErrImmutable 成员由配置决定的注册中心 (Static, File) 不能在注册后发布自身节点的变更, 否则各节点的成员信息不一致
+var ErrImmutable = errors.New("registry cannot publish node updates")
+
+// Event 成员变更事件, EventDelete 时 Node 至少包含 Name
+type Event struct {
+	Type EventType
+	Node *pb.ServiceNode
+}
+
+// Registry 服务注册中心
+type Registry interface {
+	// Register 注册自身节点, 并在 Deregister 或 Close 之前保持注册
+	Register(ctx context.Context, node *pb.ServiceNode) error
+	// Watch 监听成员变更, 首先以 EventPut 推送当前所有节点, ctx 结束或 Close 后 channel 关闭
+	Watch(ctx context.Context) (<-chan Event, error)
+	// Deregister 注销自身节点
+	Deregister(ctx context.Context) error
+	// Close 释放资源
+	Close() error
+}
+
+// diff 比较新旧成员, 返回离开的节点与新增或变化的节点
+func diff(old, new map[string]*pb.ServiceNode) []Event {
+	var events []Event
+	for key, node := range old {
+		if _, ok := new[key]; !ok {
+			events = append(events, Event{Type: EventDelete, Node: node})
+		}
+	}
+	for key, node := range new {
+		if o, ok := old[key]; ok && proto.Equal(o, node) {
+			continue
+		}
+		events = append(events, Event{Type: EventPut, Node: node})
+	}
+	return events
+}
+
+const (
+	minBackoff = time.Millisecond * 100
+	maxBackoff = time.Second * 10
+)
+
+// backoff 指数退避, 附带随机抖动避免所有节点同时重试
+type backoff struct {
+	cur time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	if b.cur == 0 {
+		b.cur = minBackoff
+	} else if b.cur *= 2; b.cur > maxBackoff {
+		b.cur = maxBackoff
+	}
+	return b.cur/2 + time.Duration(rand.Int63n(int64(b.cur/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.cur = 0
+}
@@ -0,0 +1,176 @@
+package registry
+
+import (
+	"context"
+	"goCache/pb"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func next(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return Event{}
+}
+
+func TestStatic(t *testing.T) {
+	r := NewStaticAddrs("localhost:8001", "localhost:8002")
+	defer r.Close()
+	r.Register(context.Background(), &pb.ServiceNode{Name: "localhost:8003", Addr: "localhost:8003", Weight: 1})
+
+	ch, err := r.Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		ev := next(t, ch)
+		if ev.Type != EventPut {
+			t.Fatalf("expected put event, got %s", ev.Type)
+		}
+		names[ev.Node.GetName()] = true
+	}
+	if len(names) != 3 {
+		t.Fatalf("expected 3 nodes, got %v", names)
+	}
+
+	r.Close()
+	if _, ok := <-ch; ok {
+		t.Fatal("expected channel to be closed")
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// 文件系统的修改时间精度可能不足, 显式设置
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write(`[{"name": "node1", "addr": "localhost:8001", "zone": "az1"}, {"addr": "localhost:8002", "weight": 2}]`, now)
+
+	r := NewFile(path, time.Millisecond*5)
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := r.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]*pb.ServiceNode)
+	for i := 0; i < 2; i++ {
+		ev := next(t, ch)
+		nodes[ev.Node.GetName()] = ev.Node
+	}
+	if nodes["node1"].GetWeight() != 1 || nodes["node1"].GetZone() != "az1" || nodes["localhost:8002"].GetWeight() != 2 {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+
+	// 格式错误时保留当前成员
+	write(`[{"name": `, now.Add(time.Second))
+	time.Sleep(time.Millisecond * 20)
+
+	write(`[{"name": "node1", "addr": "localhost:8001", "zone": "az1"}, {"name": "node3", "addr": "localhost:8003"}]`, now.Add(time.Second*2))
+	events := make(map[string]EventType)
+	for i := 0; i < 2; i++ {
+		ev := next(t, ch)
+		events[ev.Node.GetName()] = ev.Type
+	}
+	if len(events) != 2 || events["localhost:8002"] != EventDelete || events["node3"] != EventPut {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := map[string]*pb.ServiceNode{
+		"key1": {Name: "node1", Addr: "localhost:8001", Weight: 1},
+		"key2": {Name: "node2", Addr: "localhost:8002", Weight: 1},
+		"key3": {Name: "node3", Addr: "localhost:8003", Weight: 1},
+	}
+	new := map[string]*pb.ServiceNode{
+		"key1": {Name: "node1", Addr: "localhost:8001", Weight: 1},
+		"key2": {Name: "node2", Addr: "localhost:8002", Weight: 2},
+		"key4": {Name: "node4", Addr: "localhost:8004", Weight: 1},
+	}
+	events := make(map[string]EventType)
+	for _, ev := range diff(old, new) {
+		events[ev.Node.GetName()] = ev.Type
+	}
+	want := map[string]EventType{"node2": EventPut, "node3": EventDelete, "node4": EventPut}
+	if len(events) != len(want) {
+		t.Fatalf("expected %v, got %v", want, events)
+	}
+	for name, typ := range want {
+		if events[name] != typ {
+			t.Errorf("expected %s %s, got %s", name, typ, events[name])
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	old := &pb.ServiceNode{Name: "node1", Addr: "localhost:8001", Weight: 1}
+	renewed := &pb.ServiceNode{Name: "node1", Addr: "localhost:8001", Weight: 2}
+	nodes := map[string]etcdNode{
+		"prefix-1": {node: old, rev: 1},
+		"prefix-2": {node: &pb.ServiceNode{Name: "node2", Addr: "localhost:8002", Weight: 1}, rev: 2},
+	}
+	members := byName(nodes)
+
+	// 重新注册后旧租约的 key 过期, 节点仍然存在
+	nodes["prefix-3"] = etcdNode{node: renewed, rev: 3}
+	next := byName(nodes)
+	if events := diff(members, next); len(events) != 1 || events[0].Type != EventPut || events[0].Node != renewed {
+		t.Fatalf("expected put of the renewed node, got %v", events)
+	}
+	members = next
+	delete(nodes, "prefix-1")
+	next = byName(nodes)
+	if events := diff(members, next); len(events) != 0 {
+		t.Fatalf("expected no events when a stale key expires, got %v", events)
+	}
+	members = next
+
+	// 最后一个 key 删除时节点离开
+	delete(nodes, "prefix-3")
+	if events := diff(members, byName(nodes)); len(events) != 1 || events[0].Type != EventDelete || events[0].Node.GetName() != "node1" {
+		t.Fatalf("expected delete of node1, got %v", events)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var b backoff
+	prev := time.Duration(0)
+	for i := 0; i < 20; i++ {
+		d := b.next()
+		if d < b.cur/2 || d > b.cur || b.cur > maxBackoff {
+			t.Fatalf("backoff %v out of range, cur %v", d, b.cur)
+		}
+		if b.cur < prev {
+			t.Fatalf("backoff decreased from %v to %v", prev, b.cur)
+		}
+		prev = b.cur
+	}
+	if b.cur != maxBackoff {
+		t.Fatalf("expected backoff to reach %v, got %v", maxBackoff, b.cur)
+	}
+	b.reset()
+	if d := b.next(); d > minBackoff {
+		t.Fatalf("expected backoff to reset, got %v", d)
+	}
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"goCache/pb"
+	"google.golang.org/protobuf/proto"
+	"sync"
+)
+
+// Static 固定成员的注册中心, 成员为构造时传入的节点与自身节点
+type Static struct {
+	mu    sync.Mutex
+	nodes []*pb.ServiceNode
+	self  *pb.ServiceNode
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewStatic(nodes ...*pb.ServiceNode) *Static {
+	return &Static{
+		nodes: nodes,
+		done:  make(chan struct{}),
+	}
+}
+
+// NewStaticAddrs 以地址作为节点名称, 权重均为 1
+func NewStaticAddrs(addrs ...string) *Static {
+	nodes := make([]*pb.ServiceNode, 0, len(addrs))
+	for _, addr := range addrs {
+		nodes = append(nodes, &pb.ServiceNode{Name: addr, Addr: addr, Weight: 1})
+	}
+	return NewStatic(nodes...)
+}
+
+func (s *Static) Register(ctx context.Context, node *pb.ServiceNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.self != nil && !proto.Equal(s.self, node) {
+		return ErrImmutable
+	}
+	s.self = node
+	return nil
+}
+
+func (s *Static) Watch(ctx context.Context) (<-chan Event, error) {
+	s.mu.Lock()
+	nodes := s.nodes
+	if s.self != nil && !contains(nodes, s.self.GetName()) {
+		nodes = append(nodes[:len(nodes):len(nodes)], s.self)
+	}
+	s.mu.Unlock()
+
+	ch := make(chan Event, eventBuffer)
+	go func() {
+		defer close(ch)
+		for _, node := range nodes {
+			select {
+			case ch <- Event{Type: EventPut, Node: node}:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+	}()
+	return ch, nil
+}
+
+func (s *Static) Deregister(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.self = nil
+	return nil
+}
+
+func (s *Static) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+func contains(nodes []*pb.ServiceNode, name string) bool {
+	for _, node := range nodes {
+		if node.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
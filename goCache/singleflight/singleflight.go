@@ -1,39 +1,153 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done     chan struct{}
+	val      interface{}
+	err      error
+	ctx      context.Context         // fn 的 ctx
+	waiters  int                     // 等待结果的调用者数量
+	cancel   context.CancelCauseFunc // 取消 fn 的 ctx
+	deadline time.Time               // fn 的截止时间, limited 为 false 时没有截止时间
+	limited  bool
+	timer    *time.Timer // 到达截止时间时取消 fn
+}
+
+// extend 将 fn 的截止时间延后到 ctx 的截止时间, ctx 没有截止时间时 fn 也不再有截止时间, 调用方持有锁
+func (c *call) extend(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	switch {
+	case c.waiters == 1 && ok:
+		c.deadline, c.limited = deadline, true
+		c.timer = time.AfterFunc(time.Until(deadline), func() {
+			c.cancel(context.DeadlineExceeded)
+		})
+	case !c.limited:
+	case !ok:
+		c.deadline, c.limited = time.Time{}, false
+		c.timer.Stop()
+	case deadline.After(c.deadline):
+		c.deadline = deadline
+		c.timer.Reset(time.Until(deadline))
+	}
+}
+
+// stop 以 cause 取消 fn 的 ctx 并停止计时, 调用方持有锁
+func (c *call) stop(cause error) {
+	c.cancel(cause)
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// flightCtx fn 的 ctx, 截止时间随等待者的加入延后
+type flightCtx struct {
+	context.Context
+	f *Flight
+	c *call
+}
+
+func (x *flightCtx) Deadline() (time.Time, bool) {
+	x.f.mu.Lock()
+	defer x.f.mu.Unlock()
+	return x.c.deadline, x.c.limited
+}
+
+func (x *flightCtx) Err() error {
+	err := x.Context.Err()
+	if err != nil && errors.Is(context.Cause(x.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
 }
 
 type Flight struct {
-	mu sync.Mutex
-	m  map[string]*call
+	mu    sync.Mutex
+	m     map[string]*call
+	calls atomic.Int64 // 执行 fn 的次数
+	dups  atomic.Int64 // 等待其他调用结果的次数
+}
+
+// Stats singleflight 统计信息
+type Stats struct {
+	Calls int64 // 实际执行的次数
+	Dups  int64 // 被合并的次数
 }
 
 func (f *Flight) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return f.DoContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// DoContext 与 Do 相同, 但每个调用者只等待到自己的 ctx 结束, 此时立即返回 ctx.Err()
+// fn 在独立的 goroutine 中执行, 其 ctx 保留第一个调用者 ctx 的值但不随任何一个调用者取消,
+// 只有所有调用者都已返回时才取消, 之后的调用重新执行 fn
+// fn 的截止时间为所有调用者中最晚的截止时间, 有调用者没有截止时间时不设置; 超过截止时间后 fn 被取消, 之后的调用同样重新执行 fn
+func (f *Flight) DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
 	f.mu.Lock()
 	if f.m == nil {
 		f.m = make(map[string]*call)
 	}
-	if call, ok := f.m[key]; ok {
+	if c, ok := f.m[key]; ok && c.ctx.Err() == nil {
+		c.waiters++
+		c.extend(ctx)
 		f.mu.Unlock()
-		call.wg.Wait()
-		return call.val, call.err
+		f.dups.Add(1)
+		return f.wait(ctx, key, c)
 	}
-	c := new(call)
-	c.wg.Add(1)
+	inner, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	c := &call{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	c.ctx = &flightCtx{Context: inner, f: f, c: c}
+	c.extend(ctx)
 	f.m[key] = c
 	f.mu.Unlock()
 
-	c.val, c.err = fn()
-	c.wg.Done()
+	f.calls.Add(1)
+	go func() {
+		c.val, c.err = fn(c.ctx)
+		close(c.done)
 
-	f.mu.Lock()
-	delete(f.m, key)
-	f.mu.Unlock()
+		f.mu.Lock()
+		c.stop(nil)
+		if f.m[key] == c {
+			delete(f.m, key)
+		}
+		f.mu.Unlock()
+	}()
+	return f.wait(ctx, key, c)
+}
+
+// wait 等待 c 的结果或 ctx 结束, 最后一个调用者离开时取消 fn 并不再复用 c
+func (f *Flight) wait(ctx context.Context, key string, c *call) (interface{}, error) {
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		f.mu.Lock()
+		if c.waiters--; c.waiters == 0 {
+			// 最后一个调用者因截止时间离开时 fn 同样以 DeadlineExceeded 结束
+			c.stop(ctx.Err())
+			if f.m[key] == c {
+				delete(f.m, key)
+			}
+		}
+		f.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
 
-	return c.val, c.err
+// Stats 获取统计信息
+func (f *Flight) Stats() Stats {
+	return Stats{
+		Calls: f.calls.Load(),
+		Dups:  f.dups.Load(),
+	}
 }
@@ -0,0 +1,218 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlight_Stats(t *testing.T) {
+	var (
+		f     Flight
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			<-start
+			f.Do("key", func() (interface{}, error) {
+				time.Sleep(time.Millisecond * 50)
+				return "value", nil
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	stats := f.Stats()
+	if stats.Calls+stats.Dups != 10 {
+		t.Fatalf("Expected 10 calls in total, got %+v", stats)
+	}
+	if stats.Calls != 1 {
+		t.Errorf("Expected 1 call, got %+v", stats)
+	}
+}
+
+func TestFlight_DoContext(t *testing.T) {
+	var (
+		f       Flight
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	go f.Do("key", func() (interface{}, error) {
+		close(started)
+		<-release
+		return "value", nil
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err := f.DoContext(ctx, "key", func(context.Context) (interface{}, error) {
+		t.Fatalf("fn should not be called while another call is in flight")
+		return nil, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestFlight_DoContextCancel(t *testing.T) {
+	var (
+		f       Flight
+		started = make(chan struct{})
+		release = make(chan struct{})
+		first   = make(chan error, 1)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, err := f.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			select {
+			case <-release:
+				return "value", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+		first <- err
+	}()
+	<-started
+
+	// 第一个调用者取消不影响其他等待者
+	second := make(chan interface{}, 1)
+	go func() {
+		v, _ := f.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+			t.Errorf("fn should not be called while another call is in flight")
+			return nil, nil
+		})
+		second <- v
+	}()
+	for f.Stats().Dups != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("Expected canceled, got %v", err)
+	}
+	close(release)
+	if v := <-second; v != "value" {
+		t.Fatalf("Expected value, got %v", v)
+	}
+}
+
+func TestFlight_DoContextAbandon(t *testing.T) {
+	var (
+		f       Flight
+		stopped = make(chan struct{})
+	)
+	// 所有调用者都返回后取消 fn 的 ctx, 之后的调用重新执行
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err := f.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	<-stopped
+	v, err := f.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+		return "value", nil
+	})
+	if err != nil || v != "value" {
+		t.Fatalf("Expected value, got %v %v", v, err)
+	}
+}
+
+func TestFlight_DoContextDeadline(t *testing.T) {
+	first, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	second, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	want, _ := first.Deadline()
+	later, _ := second.Deadline()
+
+	// fn 的截止时间先为第一个调用者的截止时间, 截止时间更晚的调用者加入后延后;
+	// 没有截止时间的调用者加入后 fn 也不再有截止时间
+	for _, tc := range []struct {
+		name   string
+		joiner context.Context
+		want   time.Time
+		ok     bool
+	}{
+		{"later", second, later, true},
+		{"unlimited", context.Background(), time.Time{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				f         Flight
+				started   = make(chan struct{})
+				release   = make(chan struct{})
+				deadlines = make(chan time.Time, 1)
+				results   = make(chan interface{}, 2)
+			)
+			fn := func(ctx context.Context) (interface{}, error) {
+				if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(want) {
+					return nil, errors.New("expected the first caller's deadline")
+				}
+				close(started)
+				<-release
+				deadline, ok := ctx.Deadline()
+				if ok != tc.ok {
+					return nil, errors.New("unexpected deadline")
+				}
+				deadlines <- deadline
+				return "value", nil
+			}
+			go func() {
+				v, err := f.DoContext(first, "key", fn)
+				if err != nil {
+					t.Error(err)
+				}
+				results <- v
+			}()
+			<-started
+			go func() {
+				v, _ := f.DoContext(tc.joiner, "key", fn)
+				results <- v
+			}()
+			for f.Stats().Dups != 1 {
+				time.Sleep(time.Millisecond)
+			}
+			close(release)
+			if deadline := <-deadlines; !deadline.Equal(tc.want) {
+				t.Fatalf("Expected deadline %v, got %v", tc.want, deadline)
+			}
+			for i := 0; i < 2; i++ {
+				if v := <-results; v != "value" {
+					t.Fatalf("Expected value, got %v", v)
+				}
+			}
+		})
+	}
+}
+
+func TestFlight_DoContextExpired(t *testing.T) {
+	var (
+		f    Flight
+		errs = make(chan error, 1)
+	)
+	// 超过截止时间后 fn 的 ctx 以 DeadlineExceeded 结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	go f.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	if err := <-errs; err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+}
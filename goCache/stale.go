@@ -0,0 +1,73 @@
+package goCache
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const revalidateTimeout = time.Second * 10
+
+// stale 是否已超过软过期时间
+func (b ByteView) stale(now int64) bool {
+	return b.soft != 0 && now > b.soft
+}
+
+// expiring 是否会在 deadline 之前软过期或过期, 只有通过 Getter 加载的数据才会提前刷新
+func (b ByteView) expiring(deadline int64) bool {
+	if !b.loaded {
+		return false
+	}
+	if b.soft != 0 {
+		return b.soft <= deadline
+	}
+	return b.expire != 0 && b.expire <= deadline
+}
+
+// setLoaded 缓存通过 Getter 加载的数据, 启用 stale-while-revalidate 时记录软过期时间并以硬过期时间过期
+func (c *Group) setLoaded(key string, value []byte) ByteView {
+	now := time.Now()
+	if c.softTTL <= 0 {
+		v := ByteView{b: value, expire: now.Add(defaultExpire).UnixMilli(), loaded: true}
+		c.mainCache.Set(key, v, defaultExpire)
+		return v
+	}
+	v := ByteView{b: value, soft: now.Add(c.softTTL).UnixMilli(), expire: now.Add(c.hardTTL).UnixMilli(), loaded: true}
+	c.mainCache.Set(key, v, c.hardTTL)
+	return v
+}
+
+// setHot 缓存从其他节点加载的热点数据, 启用 stale-while-revalidate 时以软过期时间过期
+// 其他节点的 key 不在本节点后台刷新, 因此热点副本不会在超过软过期时间后继续返回
+func (c *Group) setHot(key string, value []byte) ByteView {
+	ttl := defaultExpire
+	if c.softTTL > 0 {
+		ttl = c.softTTL
+	}
+	v := ByteView{b: value, expire: time.Now().Add(ttl).UnixMilli()}
+	c.hotCache.Set(key, v, ttl)
+	return v
+}
+
+// revalidate v 超过软过期时间时在后台通过 singleflight 重新加载, 同一个 key 同时只有一个刷新
+func (c *Group) revalidate(key string, v ByteView) {
+	if !v.stale(time.Now().UnixMilli()) {
+		return
+	}
+	c.stats.staleHits.Add(1)
+	if _, loading := c.refresh.LoadOrStore(key, struct{}{}); loading {
+		return
+	}
+	c.stats.revalidations.Add(1)
+	go func() {
+		defer c.refresh.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+		_, err := c.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return c.loadLocally(ctx, key)
+		})
+		if err != nil {
+			log.Printf("[%s] failed to revalidate key %s, err: %v\n", c.name, key, err)
+		}
+	}()
+}
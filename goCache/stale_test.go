@@ -0,0 +1,94 @@
+package goCache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_StaleWhileRevalidate(t *testing.T) {
+	var (
+		version atomic.Int32
+		release = make(chan struct{})
+	)
+	group := NewGroup("stale", GetterFunc(func(key string) ([]byte, error) {
+		// 第一次之后的加载阻塞到 release 关闭
+		if version.Add(1) > 1 {
+			<-release
+		}
+		return []byte(fmt.Sprintf("%s-v%d", key, version.Load())), nil
+	}), WithCacheOptionsStaleWhileRevalidate(time.Millisecond*20, time.Second))
+
+	if v, err := group.Get("key"); err != nil || v.String() != "key-v1" {
+		t.Fatalf("expected key-v1, got %v %v", v, err)
+	}
+	time.Sleep(time.Millisecond * 30)
+
+	// 超过软过期时间后立即返回旧数据, 只触发一次后台刷新
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := group.Get("key"); err != nil || v.String() != "key-v1" {
+				t.Errorf("expected stale key-v1, got %v %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := group.Stats(); stats.StaleHits != 10 || stats.Revalidations != 1 {
+		t.Fatalf("expected 10 stale hits and 1 revalidation, got %+v", stats)
+	}
+
+	close(release)
+	waitFor(t, func() bool {
+		v, _ := group.Get("key")
+		return v.String() == "key-v2"
+	})
+	if n := version.Load(); n != 2 {
+		t.Fatalf("expected 2 loads, got %d", n)
+	}
+}
+
+func TestGroup_StaleWhileRevalidate_HardTTL(t *testing.T) {
+	var loads atomic.Int32
+	group := NewGroup("stale-hard", GetterFunc(func(key string) ([]byte, error) {
+		return []byte(fmt.Sprintf("%s-v%d", key, loads.Add(1))), nil
+	}), WithCacheOptionsStaleWhileRevalidate(time.Millisecond*10, time.Millisecond*20))
+
+	group.Get("key")
+	time.Sleep(time.Millisecond * 30)
+	// 超过硬过期时间后同步加载
+	if v, err := group.Get("key"); err != nil || v.String() != "key-v2" {
+		t.Fatalf("expected synchronous load of key-v2, got %v %v", v, err)
+	}
+	if stats := group.Stats(); stats.StaleHits != 0 {
+		t.Fatalf("expected no stale hits, got %d", stats.StaleHits)
+	}
+}
+
+func TestGroup_StaleWhileRevalidate_HotCache(t *testing.T) {
+	group := NewGroup("stale-hot", GetterFunc(func(key string) ([]byte, error) {
+		t.Errorf("key %s should be loaded by the peer", key)
+		return nil, nil
+	}), WithCacheOptionsStaleWhileRevalidate(time.Millisecond*20, time.Second))
+	group.RegisterPeer(&fakePeer{getter: &fakeGetter{}})
+
+	// 从其他节点加载的数据以一定概率进入 hotCache
+	for i := 0; !group.cached("remote-1"); i++ {
+		if i == 1000 {
+			t.Fatal("expected remote key to be hot cached")
+		}
+		group.Get("remote-1")
+	}
+	// 热点副本在软过期时间后过期, 而不是返回旧数据
+	time.Sleep(time.Millisecond * 30)
+	if group.cached("remote-1") {
+		t.Fatal("expected hot cached copy to expire after the soft TTL")
+	}
+	if stats := group.Stats(); stats.StaleHits != 0 {
+		t.Fatalf("expected no stale hits, got %d", stats.StaleHits)
+	}
+}
@@ -0,0 +1,62 @@
+package goCache
+
+import (
+	"goCache/goCache/cache"
+	"sync/atomic"
+)
+
+// groupStats group 运行时计数器
+type groupStats struct {
+	gets          atomic.Int64 // Get 次数
+	mainCacheHits atomic.Int64 // mainCache 命中次数
+	hotCacheHits  atomic.Int64 // hotCache 命中次数
+	localLoads    atomic.Int64 // 通过 Getter 加载次数
+	peerLoads     atomic.Int64 // 从 peer 加载次数
+	peerErrors    atomic.Int64 // 从 peer 加载失败次数
+	loaderErrors  atomic.Int64 // Getter 加载失败次数
+	staleHits     atomic.Int64 // 返回超过软过期时间的数据的次数
+	revalidations atomic.Int64 // 后台刷新次数
+	refreshAheads atomic.Int64 // 提前刷新次数
+	negativeHits  atomic.Int64 // 负缓存命中次数
+}
+
+// GroupStats group 统计信息快照
+type GroupStats struct {
+	Gets          int64
+	MainCacheHits int64
+	HotCacheHits  int64
+	LocalLoads    int64
+	PeerLoads     int64
+	PeerErrors    int64
+	LoaderErrors  int64
+	StaleHits     int64
+	Revalidations int64
+	RefreshAheads int64
+	NegativeHits  int64
+	LoadsDeduped  int64 // singleflight 合并的加载次数
+	Evictions     int64 // mainCache 与 hotCache 淘汰次数之和
+	MainCache     cache.Stats
+	HotCache      cache.Stats
+}
+
+// Stats 获取 group 统计信息快照
+func (c *Group) Stats() GroupStats {
+	stats := GroupStats{
+		Gets:          c.stats.gets.Load(),
+		MainCacheHits: c.stats.mainCacheHits.Load(),
+		HotCacheHits:  c.stats.hotCacheHits.Load(),
+		LocalLoads:    c.stats.localLoads.Load(),
+		PeerLoads:     c.stats.peerLoads.Load(),
+		PeerErrors:    c.stats.peerErrors.Load(),
+		LoaderErrors:  c.stats.loaderErrors.Load(),
+		StaleHits:     c.stats.staleHits.Load(),
+		Revalidations: c.stats.revalidations.Load(),
+		RefreshAheads: c.stats.refreshAheads.Load(),
+		NegativeHits:  c.stats.negativeHits.Load(),
+		LoadsDeduped:  c.loader.Stats().Dups,
+		MainCache:     c.mainCache.Stats(),
+		HotCache:      c.hotCache.Stats(),
+	}
+	stats.Evictions = stats.MainCache.Evictions + stats.HotCache.Evictions
+	return stats
+}
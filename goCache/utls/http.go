@@ -2,6 +2,7 @@ package utls
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,30 +10,30 @@ import (
 
 /* HTTP 请求封装 */
 
-func Get(url string, body []byte) (*http.Response, error) {
+func Get(ctx context.Context, url string, body []byte) (*http.Response, error) {
 	reader := bytes.NewReader(body)
-	return Request(http.MethodGet, url, reader)
+	return Request(ctx, http.MethodGet, url, reader)
 }
 
-func Delete(url string) (*http.Response, error) {
+func Delete(ctx context.Context, url string) (*http.Response, error) {
 
-	return Request(http.MethodDelete, url, nil)
+	return Request(ctx, http.MethodDelete, url, nil)
 }
 
-func Post(url string, body []byte) (*http.Response, error) {
+func Post(ctx context.Context, url string, body []byte) (*http.Response, error) {
 	reader := bytes.NewReader(body)
-	return Request(http.MethodPost, url, reader)
+	return Request(ctx, http.MethodPost, url, reader)
 }
 
-func Request(method string, url string, body io.Reader) (resp *http.Response, err error) {
+func Request(ctx context.Context, method string, url string, body io.Reader) (resp *http.Response, err error) {
 	client := http.Client{}
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to new request, err: %v", err)
 	}
 	resp, err = client.Do(req)
 	if err != nil {
-		return resp, fmt.Errorf("failed to sent request, err: %v", err)
+		return resp, fmt.Errorf("failed to sent request, err: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		return resp, fmt.Errorf("faield to send request, status: %s", resp.Status)
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"goCache/goCache"
+	"goCache/goCache/consistent"
+	"goCache/goCache/registry"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
 var (
@@ -13,34 +22,98 @@ var (
 		"Tom": "123",
 		"Jak": "354",
 	}
-	addr     = flag.String("addr", "http://localhost:8000", "address")
-	api      = flag.Bool("api", false, "enable flag")
-	etcdAddr = "http://162.14.115.114:2379"
+	addr      = flag.String("addr", "http://localhost:8000", "address")
+	api       = flag.Bool("api", false, "enable flag")
+	admin     = flag.String("admin", "", "admin address, serves /metrics")
+	peers     = flag.String("peers", "", "comma separated static peer addresses, disables etcd")
+	peersFile = flag.String("peers-file", "", "JSON file of peers, reloaded on change, disables etcd")
+	gossip    = flag.String("gossip", "", "UDP address for gossip membership, disables etcd")
+	seeds     = flag.String("seeds", "", "comma separated gossip addresses of existing members")
+	picker    = flag.String("picker", "ring", "placement algorithm: ring, rendezvous, jump or maglev")
+	zone      = flag.String("zone", "", "availability zone of this node, replicas are spread across zones")
+	rack      = flag.String("rack", "", "rack of this node")
+	host      = flag.String("host", "", "host of this node")
+	weight    = flag.Int("weight", 1, "weight of this node in the hash ring, can be changed at runtime via POST /weight on the admin address")
+	etcdAddr  = "http://162.14.115.114:2379"
+)
+
+const (
+	shutdownTimeout = time.Second * 10
+	negativeTTL     = time.Second * 5
+	negativeBytes   = 1 << 20
 )
 
 func main() {
 	flag.Parse()
-	ch := make(chan syscall.Signal)
-	peer := goCache.NewGrpcPeer(*addr, etcdAddr)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	var options []goCache.PeerOptionFunc
+	switch {
+	case *gossip != "":
+		var seedAddrs []string
+		if *seeds != "" {
+			seedAddrs = strings.Split(*seeds, ",")
+		}
+		r, err := registry.NewGossip(*gossip, registry.DefaultGossipConfig(), seedAddrs...)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, goCache.WithPeerOptionsRegistry(r))
+	case *peersFile != "":
+		options = append(options, goCache.WithPeerOptionsRegistry(registry.NewFile(*peersFile, 0)))
+	case *peers != "":
+		options = append(options, goCache.WithPeerOptionsRegistry(registry.NewStaticAddrs(strings.Split(*peers, ",")...)))
+	}
+	p, err := consistent.NewPicker(*picker)
+	if err != nil {
+		panic(err)
+	}
+	options = append(options,
+		goCache.WithPeerOptionsPicker(p),
+		goCache.WithPeerOptionsTopology(*zone, *rack, *host),
+		goCache.WithPeerOptionsWeight(int32(*weight)),
+	)
+	peer := goCache.NewGrpcPeerWithOptions(*addr, []string{etcdAddr}, options...)
 	peer.StartService()
+	if *admin != "" {
+		peer.ServeAdmin(*admin)
+	}
 
 	group := goCache.NewGroup("score", goCache.GetterFunc(func(key string) ([]byte, error) {
 		if v, ok := db[key]; ok {
 			return []byte(v), nil
 		}
-		return nil, fmt.Errorf("getter not found, key: %s", key)
-	}))
+		return nil, fmt.Errorf("getter not found, key: %s, err: %w", key, goCache.ErrNotFound)
+	}), goCache.WithCacheOptionsNegative(negativeTTL, negativeBytes))
 	group.RegisterPeer(peer)
+	var apiServer *http.Server
 	if *api {
-		StartAPI(group)
+		apiServer = StartAPI(group)
 	}
-	<-ch
+	sig := <-ch
+	log.Println("received signal, shutting down, ", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if apiServer != nil {
+		apiServer.Shutdown(ctx)
+	}
+	if err := peer.Shutdown(ctx); err != nil {
+		log.Println("failed to shutdown peer, err: ", err)
+	}
+	group.Close()
 }
 
-func StartAPI(cache *goCache.Group) {
-	http.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+func StartAPI(cache *goCache.Group) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
 		key := request.URL.Query().Get("key")
-		value, err := cache.Get(key)
+		value, err := cache.GetContext(request.Context(), key)
+		if errors.Is(err, goCache.ErrNotFound) {
+			writer.WriteHeader(http.StatusNotFound)
+			writer.Write([]byte(err.Error()))
+			return
+		}
 		if err != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			writer.Write([]byte(err.Error()))
@@ -49,5 +122,11 @@ func StartAPI(cache *goCache.Group) {
 		writer.WriteHeader(http.StatusOK)
 		writer.Write(value.Slice())
 	})
-	http.ListenAndServe(":9999", nil)
+	server := &http.Server{Addr: ":9999", Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println("api server stopped, err: ", err)
+		}
+	}()
+	return server
 }